@@ -0,0 +1,105 @@
+package fiptoml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//a tenant config: a few tables of scalars and arrays
+const benchConfig = `# tenant configuration
+title = "Tenant"
+enabled = true
+version = 3
+ratio = 0.75
+created = 2016-05-27T07:32:00Z
+
+[owner]
+name = "Lance Uppercut"
+email = "lance@example.com"
+bio = """
+Roses are red \
+  violets are blue.
+"""
+path = 'C:\Users\lance'
+
+[database]
+server = "192.168.1.1"
+ports = [ 8001, 8001, 8002 ]
+connection_max = 5000
+timeout = 2.5
+enabled = true
+hosts = [ "alpha", "omega", "beta" ]
+
+[servers.alpha]
+ip = "10.0.0.1"
+dc = "eqdc10"
+
+[servers.beta]
+ip = "10.0.0.2"
+dc = "eqdc10"
+`
+
+//a generated export, thousand of records
+var benchRecords = func() string {
+	var b strings.Builder
+	b.WriteString("title = \"export\"\n\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "[[records]]\nid = %d\nname = \"record %d\"\nscore = %d.5\ntags = [ \"a\", \"b\", \"c\" ]\nat = 2016-05-27T07:32:00Z\n\n", i, i, i)
+	}
+	return b.String()
+}()
+
+func BenchmarkParseConfig(b *testing.B) {
+	input := []byte(benchConfig)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseRecords(b *testing.B) {
+	input := []byte(benchRecords)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEachRecords(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchRecords)))
+	for i := 0; i < b.N; i++ {
+		err := Each(strings.NewReader(benchRecords), "records", func(t *Toml) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractValue(b *testing.B) {
+	values := [][]byte{
+		[]byte(`"a string\twith \u00e9scapes"`),
+		[]byte(`738594937`),
+		[]byte(`-3.1415`),
+		[]byte(`1979-05-27T07:32:00-08:00`),
+		[]byte(`[ 8001, 8001, 8002 ]`),
+		[]byte(`true`),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, v := range values {
+			if _, _, err := extractValue(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package fiptoml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//Clone returns a deep copy of the document sharing nothing with it.
+func (t *Toml) Clone() *Toml {
+	c := copyTable(t)
+	c.name = t.name
+	if t.origins != nil {
+		c.origins = make(map[string]string, len(t.origins))
+		for path, origin := range t.origins {
+			c.origins[path] = origin
+		}
+	}
+	return c
+}
+
+func copyTable(t *Toml) *Toml {
+	c := NewToml()
+	for _, key := range t.orderedKeys() {
+		c.set(key, copyValue(t.dict[key]))
+	}
+	return c
+}
+
+//copyValue copies tables and arrays so that nothing is shared with v
+func copyValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *Toml:
+		return copyTable(x)
+	case []*Toml:
+		array := make([]*Toml, len(x))
+		for i, table := range x {
+			array[i] = copyTable(table)
+		}
+		return array
+	case []string:
+		return append(make([]string, 0, len(x)), x...)
+	case []int:
+		return append(make([]int, 0, len(x)), x...)
+	case []float64:
+		return append(make([]float64, 0, len(x)), x...)
+	case []bool:
+		return append(make([]bool, 0, len(x)), x...)
+	case []time.Time:
+		return append(make([]time.Time, 0, len(x)), x...)
+	case []interface{}:
+		return append(make([]interface{}, 0, len(x)), x...)
+	default:
+		return v
+	}
+}
+
+//Equal tells whether a and b hold the same values. Datetimes are equal when
+//they are the same instant, whatever their time zones, and NaN equals NaN.
+//Integers never equal floats, and empty arrays are equal whatever their types.
+func Equal(a, b *Toml) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return valuesEqual(a, b)
+}
+
+//valuesEqual compares values of documents, datetimes as instants and NaN being
+//equal to NaN
+func valuesEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		return ok && (x == y || math.IsNaN(x) && math.IsNaN(y))
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case *Toml:
+		y, ok := b.(*Toml)
+		if !ok || len(x.dict) != len(y.dict) {
+			return false
+		}
+		for key, v := range x.dict {
+			w, ok := y.dict[key]
+			if !ok || !valuesEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []string, []int, []bool, []float64, []time.Time, []interface{}, []*Toml:
+		va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+		if vb.Kind() != reflect.Slice || va.Len() != vb.Len() {
+			return false
+		}
+		//empty arrays are equal whatever their type of elements
+		if va.Len() == 0 {
+			return true
+		}
+		if va.Type() != vb.Type() {
+			return false
+		}
+		for i := 0; i < va.Len(); i++ {
+			if !valuesEqual(va.Index(i).Interface(), vb.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+//Fingerprint returns a hash of the canonical form of the document, the same
+//for documents that are Equal.
+func (t *Toml) Fingerprint() string {
+	h := sha256.New()
+	writeCanonical(h, t)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//keys sorted, datetimes in UTC, every value tagged with its kind
+func writeCanonical(h hash.Hash, v interface{}) {
+	switch x := v.(type) {
+	case *Toml:
+		io.WriteString(h, "{")
+		for _, key := range x.sortedKeys() {
+			io.WriteString(h, strconv.Quote(key)+"=")
+			writeCanonical(h, x.dict[key])
+			io.WriteString(h, ";")
+		}
+		io.WriteString(h, "}")
+	case string:
+		io.WriteString(h, "s"+strconv.Quote(x))
+	case int:
+		io.WriteString(h, "i"+strconv.Itoa(x))
+	case float64:
+		switch {
+		case math.IsNaN(x):
+			io.WriteString(h, "fnan")
+		case x == 0:
+			//-0 equals 0
+			io.WriteString(h, "f0")
+		default:
+			io.WriteString(h, "f"+strconv.FormatFloat(x, 'g', -1, 64))
+		}
+	case bool:
+		io.WriteString(h, "b"+strconv.FormatBool(x))
+	case time.Time:
+		io.WriteString(h, "d"+x.UTC().Format(time.RFC3339Nano))
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Len() == 0 {
+			io.WriteString(h, "[]")
+			return
+		}
+		io.WriteString(h, "["+rv.Type().String()+":")
+		for i := 0; i < rv.Len(); i++ {
+			writeCanonical(h, rv.Index(i).Interface())
+			io.WriteString(h, ",")
+		}
+		io.WriteString(h, "]")
+	}
+}
@@ -0,0 +1,61 @@
+package fiptoml
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	toml, _ := ParseString(example)
+	clone := toml.Clone()
+	if !Equal(toml, clone) || toml.Fingerprint() != clone.Fingerprint() {
+		t.Log("Clone should be equal to the original")
+		t.Fail()
+	}
+
+	clone.Set("database.ports[0]", 1)
+	clone.Set("products[0].name", "Saw")
+	clone.Set("owner.name", "Tom")
+	if toml.GetInt("database.ports[0]", 0) != 8001 ||
+		toml.GetString("products[0].name", "") != "Hammer" ||
+		toml.GetString("owner.name", "") != "Lance Uppercut" {
+		t.Log("Clone should share nothing with the original")
+		t.Fail()
+	}
+	if Equal(toml, clone) || toml.Fingerprint() == clone.Fingerprint() {
+		t.Log("a modified clone should differ")
+		t.Fail()
+	}
+}
+
+func TestEqual(t *testing.T) {
+	dob := time.Date(1979, 5, 27, 7, 32, 0, 0, time.FixedZone("PST", -8*3600))
+	a, b := NewToml(), NewToml()
+	a.Set("nan", math.NaN())
+	b.Set("nan", math.NaN())
+	a.Set("dob", dob)
+	b.Set("dob", dob.UTC())
+	a.Set("zero", 0.0)
+	b.Set("zero", math.Copysign(0, -1))
+	a.Set("floats", []float64{1, math.NaN()})
+	b.Set("floats", []float64{1, math.NaN()})
+	a.Set("empty", []int{})
+	b.Set("empty", []string{})
+
+	if !Equal(a, b) || a.Fingerprint() != b.Fingerprint() {
+		t.Log("NaN, datetimes, zeros and empty arrays should be equal")
+		t.Fail()
+	}
+
+	b.Set("count", 1)
+	a.Set("count", 1.0)
+	if Equal(a, b) || a.Fingerprint() == b.Fingerprint() {
+		t.Log("an integer should not equal a float")
+		t.Fail()
+	}
+	if Equal(a, nil) || !Equal(nil, nil) {
+		t.Log("nil documents")
+		t.Fail()
+	}
+}
@@ -0,0 +1,359 @@
+package fiptoml
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+//a statement is buffered whole, documents are not
+const maxStatement = 1 << 24
+
+var (
+	errStatementTooLong = errors.New("statement too long")
+	errUnexpectedEnd    = errors.New("unexpected end of document")
+)
+
+//ParseError is an error of a document at a position. Lines and columns count
+//from 1, columns in characters.
+type ParseError struct {
+	File string
+	Line int
+	Col  int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	pos := fmt.Sprint(e.Line, ":", e.Col)
+	if len(e.File) > 0 {
+		pos = e.File + ":" + pos
+	}
+	return fmt.Sprint(pos, ": ", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//Options configures ParseWith, LoadWith and NewDecoderWith.
+type Options struct {
+	//ExpandEnv expands variables in string values, and in the strings of arrays:
+	//
+	//	${VAR}            the value of VAR, which must be set
+	//	${VAR:-default}   default when VAR is unset or empty
+	//	${VAR:?message}   fails with message when VAR is unset or empty
+	//	$$                a single $
+	ExpandEnv bool
+	//looks variables up, os.LookupEnv by default
+	LookupEnv func(name string) (string, bool)
+
+	//ResolveRefs replaces variables naming a path of the document with its
+	//value, once the document is read and its files included:
+	//
+	//	[paths]
+	//	base = "/srv/app"
+	//	logs = "${paths.base}/logs"
+	//	port = "${server.port}"   # an integer when the whole string is one
+	//
+	//References take precedence over environment variables, and may refer to
+	//values holding references themselves, but not in a cycle. A string which
+	//is a single reference gets the value referred to with its type, arrays and
+	//tables included, other strings only take strings, numbers, booleans and
+	//datetimes. Without the option references are left as written.
+	ResolveRefs bool
+
+	//Includes merges the files listed by a top level include key, and removes
+	//the key:
+	//
+	//	include = [ "defaults.toml", "conf.d/*.toml" ]
+	//
+	//Later files take precedence over earlier ones, and the including file
+	//over all of them. Relative paths are relative to the including file, the
+	//files matching a pattern are merged in sorted order, and the origin of
+	//every value is the file it came from, see Origin. Included files may
+	//include others, but not themselves.
+	Includes bool
+
+	//Profile selects a table of the top level profile table, merged over the
+	//rest of the document once its files are included, the profiles being
+	//removed:
+	//
+	//	[profile.prod]
+	//	extends = "base"
+	//	log = "warn"
+	//
+	//A profile may extend another, merged first, but not in a cycle.
+	Profile string
+}
+
+func (opts *Options) lookupEnv() func(name string) (string, bool) {
+	if opts.LookupEnv != nil {
+		return opts.LookupEnv
+	}
+	return os.LookupEnv
+}
+
+//Decoder reads a document from a stream, a statement at a time: a table
+//header, or a key and its value which may span several lines.
+type Decoder struct {
+	s     *stmtScanner
+	opts  Options
+	files files    //where included files are read from
+	stack []string //the files including this one
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWith(r, nil)
+}
+
+func NewDecoderWith(r io.Reader, opts *Options) *Decoder {
+	d := &Decoder{s: newStmtScanner(r), files: osFiles{}}
+	if opts != nil {
+		d.opts = *opts
+	}
+	return d
+}
+
+//Decode reads the document up to the end of the stream. Syntax errors are
+//*ParseError.
+func (d *Decoder) Decode() (doc *Toml, err error) {
+	doc = NewToml()
+	doc.name = d.s.file
+	table := doc
+	for {
+		st, err := d.s.next()
+		if err == io.EOF {
+			return d.finish(doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		idx := 0
+		switch st.kind {
+		case stmtTable, stmtTableArray:
+			skip := 1
+			if st.kind == stmtTableArray {
+				skip = 2
+			}
+			table, idx, err = extractTableHeader(st.text[skip:], doc, st.kind == stmtTableArray)
+			idx += skip
+		default:
+			idx, err = extractKeyValue(st.text, table)
+		}
+		if err != nil {
+			return nil, d.s.fail(st, idx, err)
+		}
+	}
+}
+
+//finish applies the options to the document read
+func (d *Decoder) finish(doc *Toml) (_ *Toml, err error) {
+	if d.opts.Includes {
+		if doc, err = d.include(doc); err != nil {
+			return
+		}
+	}
+	if len(d.opts.Profile) > 0 {
+		if doc, err = doc.SelectProfile(d.opts.Profile); err != nil {
+			return nil, d.s.wrap(err)
+		}
+	}
+	if d.opts.ExpandEnv || d.opts.ResolveRefs {
+		if err = resolveVars(doc, &d.opts); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+//wrap names the file in errors found after parsing
+func (s *stmtScanner) wrap(err error) error {
+	if len(s.file) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %w", s.file, err)
+}
+
+type stmtKind int
+
+const (
+	stmtKeyValue stmtKind = iota
+	stmtTable
+	stmtTableArray
+	stmtComment
+)
+
+//text is valid up to the next statement, and ends with a line break
+type statement struct {
+	kind      stmtKind
+	text      []byte
+	line, col int
+}
+
+type stmtScanner struct {
+	r        *bufio.Reader
+	file     string
+	comments bool //comment lines are statements too
+	buf      []byte
+	line     int //lines read
+}
+
+func newStmtScanner(r io.Reader) *stmtScanner {
+	return &stmtScanner{r: bufio.NewReader(r)}
+}
+
+//next skips blank lines, and comments unless asked for, and returns the
+//following statement
+func (s *stmtScanner) next() (st statement, err error) {
+	s.buf = s.buf[:0]
+	var line []byte
+	from := 0
+	for {
+		if line, err = s.readLine(); err != nil {
+			return
+		}
+		from = skipUntil(line, func(r rune) bool { return !unicode.IsSpace(r) }, false)
+		if from < len(line) && (line[from] != '#' || s.comments) {
+			break
+		}
+		s.buf = s.buf[:0]
+	}
+	st.line, st.col = s.line, utf8.RuneCount(line[:from])+1
+
+	switch line[from] {
+	case '#':
+		st.kind = stmtComment
+		st.text = s.buf[from:]
+		return
+	case '[':
+		st.kind = stmtTable
+		if from+1 < len(line) && line[from+1] == '[' {
+			st.kind = stmtTableArray
+		}
+		st.text = s.buf[from:]
+		return
+	}
+
+	var v valueState
+	for v.feed(line); v.open(); v.feed(line) {
+		if line, err = s.readLine(); err == io.EOF {
+			st.text = s.buf[from:]
+			return st, s.fail(st, len(st.text)-1, errUnexpectedEnd)
+		} else if err != nil {
+			return
+		}
+	}
+	st.kind = stmtKeyValue
+	st.text = s.buf[from:]
+	return
+}
+
+//readLine appends the next line to the buffer, a line break is added to the
+//last line when missing
+func (s *stmtScanner) readLine() (line []byte, err error) {
+	start := len(s.buf)
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		s.buf = append(s.buf, chunk...)
+		if len(s.buf) > maxStatement {
+			return nil, &ParseError{s.file, s.line + 1, 1, errStatementTooLong}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(s.buf) > start {
+			s.buf = append(s.buf, '\n')
+		} else if err != nil {
+			return nil, err
+		}
+
+		s.line++
+		line = s.buf[start:]
+		if !utf8.Valid(line) {
+			i := 0
+			for {
+				r, w := utf8.DecodeRune(line[i:])
+				if r == utf8.RuneError && w <= 1 {
+					break
+				}
+				i += w
+			}
+			return nil, &ParseError{s.file, s.line, utf8.RuneCount(line[:i]) + 1, errUtf8}
+		}
+		return line, nil
+	}
+}
+
+//fail locates err at the offset in the text of the statement
+func (s *stmtScanner) fail(st statement, offset int, err error) error {
+	line, col := st.pos(offset)
+	return &ParseError{s.file, line, col, err}
+}
+
+//pos returns the line and column of the offset in the text
+func (st statement) pos(offset int) (line, col int) {
+	before := st.text[:offset]
+	line, col = st.line, st.col
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		line += bytes.Count(before, []byte{'\n'})
+		before, col = before[i+1:], 1
+	}
+	return line, col + utf8.RuneCount(before)
+}
+
+//valueState follows a key/value statement line by line, it continues on the
+//next line inside an array or a multi-line string
+type valueState struct {
+	depth int
+	quote byte //the quote of an open multi-line string
+}
+
+func (v *valueState) open() bool {
+	return v.depth > 0 || v.quote != 0
+}
+
+func (v *valueState) feed(line []byte) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if v.quote != 0 {
+			if c == '\\' && v.quote == '"' {
+				i++
+			} else if isTripleQuote(line[i:], v.quote) {
+				i += 2
+				v.quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '#':
+			return
+		case '[':
+			v.depth++
+		case ']':
+			v.depth--
+		case '"', '\'':
+			if isTripleQuote(line[i:], c) {
+				v.quote = c
+				i += 2
+				continue
+			}
+			//a single line string ends with its line at the latest
+			for i++; i < len(line) && line[i] != c && line[i] != '\n'; i++ {
+				if c == '"' && line[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+}
+
+func isTripleQuote(in []byte, quote byte) bool {
+	return len(in) >= 3 && in[0] == quote && in[1] == quote && in[2] == quote
+}
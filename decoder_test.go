@@ -0,0 +1,93 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder(t *testing.T) {
+	//every read returns a single byte, values span many reads
+	toml, err := NewDecoder(iotest.OneByteReader(strings.NewReader(example))).Decode()
+	if err != nil {
+		t.Fatal("Decode should work. err:", err)
+	}
+	expected, _ := ParseString(example)
+	if !Equal(toml, expected) {
+		t.Log("Decode should read the same document as Parse")
+		t.Fail()
+	}
+	if tags := toml.GetStringArray("owner.tags"); len(tags) != 2 || tags[1] != "tag 2" {
+		t.Log("an array spanning lines should be read whole, got", tags)
+		t.Fail()
+	}
+}
+
+func TestDecoderStatements(t *testing.T) {
+	toml, err := ParseString(`title = "no blank lines" # comment
+[owner]
+name = "Tom"
+bio = """
+Roses are red
+Violets are blue [1]"""
+[[products]]
+name = "Hammer"
+[[products]]
+name = "Nail"
+ports = [ 8000,
+	8001 ]`)
+	if err != nil {
+		t.Fatal("ParseString should work. err:", err)
+	}
+	if toml.GetString("owner.name", "") != "Tom" ||
+		toml.GetString("owner.bio", "") != "Roses are red\nViolets are blue [1]" ||
+		toml.GetString("products[1].name", "") != "Nail" ||
+		len(toml.GetIntArray("products[1].ports")) != 2 {
+		t.Log("tables should not need blank lines between them")
+		t.Fail()
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		input     string
+		line, col int
+		err       error
+	}{
+		{"a = 1\nb = \nc = 3\n", 2, 5, errUnsupportedValue("b")},
+		{"[t]\nx = 1\n\n[u]\nx = 1\nx = 2\n", 6, 1, errDuplicatedKey("x")},
+		{"a = 1\n  [ ]\n", 2, 4, errInvalidTableKey},
+		{"a = 1 2\n", 1, 7, errTrailingContent},
+		{"a = [\n 1,\n 2\n", 3, 3, errUnexpectedEnd},
+		{"a = \"é\xff\"\n", 1, 7, errUtf8},
+	}
+	for _, c := range cases {
+		_, err := ParseString(c.input)
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Line != c.line || pe.Col != c.col || pe.Err.Error() != c.err.Error() {
+			t.Logf("%q: expected %d:%d: %v, got %v", c.input, c.line, c.col, c.err, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	_, err := Load("./config/config.toml")
+	if err != nil {
+		t.Fatal("Load should work. err:", err)
+	}
+	_, err = Load("./config/missing.toml")
+	if err == nil {
+		t.Log("Load should fail on a missing file")
+		t.Fail()
+	}
+}
+
+func ExampleParseError() {
+	_, err := ParseString("[database]\nport = 5432\nport = 5433\n")
+	fmt.Println(err)
+	// Output:
+	// 3:1: Duplicated key: port
+}
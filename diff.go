@@ -0,0 +1,207 @@
+package fiptoml
+
+import (
+	"sort"
+	"strings"
+)
+
+//ChangeType tells how a value differs between two documents.
+type ChangeType int
+
+const (
+	ChangeAdded ChangeType = iota + 1
+	ChangeRemoved
+	ChangeModified
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+//Change is a difference between two documents. Old is the zero Value for an
+//added path, New for a removed one.
+type Change struct {
+	Type ChangeType
+	Path string
+	Old  Value
+	New  Value
+}
+
+//DiffOptions configures DiffWith.
+type DiffOptions struct {
+	//the key field identifying the elements of arrays of tables, by path.
+	//Elements of arrays of tables may be written as [*], as in products[*].parts.
+	//Arrays of tables without a key are compared element by element by index.
+	Keys map[string]string
+}
+
+//Diff returns the changes turning a into b, in sorted order of keys. Tables
+//and arrays of tables are compared value by value, other arrays as a whole.
+//A nil document is an empty one.
+func Diff(a, b *Toml) []Change {
+	return DiffWith(a, b, nil)
+}
+
+//DiffWith is Diff with arrays of tables compared element by element by key.
+func DiffWith(a, b *Toml, opts *DiffOptions) []Change {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	if a == nil {
+		a = NewToml()
+	}
+	if b == nil {
+		b = NewToml()
+	}
+	d := &differ{opts: opts}
+	d.diffTables(a, b, nil)
+	return d.changes
+}
+
+type differ struct {
+	opts    *DiffOptions
+	changes []Change
+}
+
+func (d *differ) add(typ ChangeType, path []pathSegment, old, new interface{}) {
+	d.changes = append(d.changes, Change{typ, formatPath(path), Value{old}, Value{new}})
+}
+
+func (d *differ) diffTables(a, b *Toml, path []pathSegment) {
+	keys := a.sortedKeys()
+	for key := range b.dict {
+		if _, ok := a.dict[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := appendKey(path, key)
+		old, inA := a.dict[key]
+		new, inB := b.dict[key]
+		switch {
+		case !inA:
+			d.add(ChangeAdded, p, nil, new)
+		case !inB:
+			d.add(ChangeRemoved, p, old, nil)
+		default:
+			d.diffValues(old, new, p)
+		}
+	}
+}
+
+func (d *differ) diffValues(old, new interface{}, path []pathSegment) {
+	kind := kindOf(new)
+	switch {
+	case kindOf(old) != kind:
+		d.add(ChangeModified, path, old, new)
+	case kind == Table:
+		d.diffTables(old.(*Toml), new.(*Toml), path)
+	case kind == TableArray:
+		d.diffTableArrays(old.([]*Toml), new.([]*Toml), path)
+	case !valuesEqual(old, new):
+		d.add(ChangeModified, path, old, new)
+	}
+}
+
+func (d *differ) diffTableArrays(a, b []*Toml, path []pathSegment) {
+	key, ok := d.opts.Keys[formatPath(path)]
+	if !ok {
+		key = d.opts.Keys[formatPattern(path)]
+	}
+
+	if len(key) == 0 {
+		for i := 0; i < len(a) || i < len(b); i++ {
+			p := appendIndex(path, i)
+			switch {
+			case i >= len(a):
+				d.add(ChangeAdded, p, nil, b[i])
+			case i >= len(b):
+				d.add(ChangeRemoved, p, a[i], nil)
+			default:
+				d.diffTables(a[i], b[i], p)
+			}
+		}
+		return
+	}
+
+	//elements of a are reported at their index in a when removed, the others
+	//at their index in b
+	matched := make([]bool, len(b))
+	for i, old := range a {
+		j := findByKey(b, key, old.dict[key], matched)
+		if j < 0 {
+			d.add(ChangeRemoved, appendIndex(path, i), old, nil)
+			continue
+		}
+		matched[j] = true
+		d.diffTables(old, b[j], appendIndex(path, j))
+	}
+	for j, new := range b {
+		if !matched[j] {
+			d.add(ChangeAdded, appendIndex(path, j), nil, new)
+		}
+	}
+}
+
+//index of the first table not yet matched whose key field equals id
+func findByKey(tables []*Toml, key string, id interface{}, matched []bool) int {
+	if id == nil {
+		return -1
+	}
+	for i, table := range tables {
+		if !matched[i] && valuesEqual(table.dict[key], id) {
+			return i
+		}
+	}
+	return -1
+}
+
+//FormatDiff renders changes in a unified diff like format, one line per value
+//removed or added. Tables are rendered value by value.
+//
+//	- database.port = 5432
+//	+ database.port = 5433
+//	+ products[2].name = "Screw"
+func FormatDiff(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		segs, _ := parsePath(c.Path)
+		if c.Old.Kind() != Invalid {
+			writeDiffLines(&b, "- ", queryNode{segs, c.Old.raw})
+		}
+		if c.New.Kind() != Invalid {
+			writeDiffLines(&b, "+ ", queryNode{segs, c.New.raw})
+		}
+	}
+	return b.String()
+}
+
+func writeDiffLines(b *strings.Builder, prefix string, n queryNode) {
+	switch v := n.val.(type) {
+	case *Toml, []*Toml:
+		nodes := children(n)
+		if len(nodes) == 0 {
+			empty := "{}"
+			if _, ok := v.([]*Toml); ok {
+				empty = "[]"
+			}
+			b.WriteString(prefix + formatPath(n.segs) + " = " + empty + "\n")
+		}
+		for _, c := range nodes {
+			writeDiffLines(b, prefix, c)
+		}
+	default:
+		b.WriteString(prefix + formatPath(n.segs) + " = " + wrapVal(v) + "\n")
+	}
+}
@@ -0,0 +1,107 @@
+package fiptoml
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleFormatDiff() {
+	a, _ := ParseString(`title = "old"
+ports = [ 8001, 8002 ]
+
+[owner]
+name = "Tom"
+
+[[products]]
+name = "Hammer"
+sku = 1
+
+[[products]]
+name = "Nail"
+sku = 2
+`)
+	b, _ := ParseString(`title = "new"
+ports = [ 8001, 8002 ]
+
+[[products]]
+name = "Nail"
+sku = 3
+
+[[products]]
+name = "Screw"
+sku = 4
+`)
+	changes := DiffWith(a, b, &DiffOptions{Keys: map[string]string{"products": "name"}})
+	fmt.Print(FormatDiff(changes))
+	// Output:
+	// - owner.name = "Tom"
+	// - products[0].name = "Hammer"
+	// - products[0].sku = 1
+	// - products[0].sku = 2
+	// + products[0].sku = 3
+	// + products[1].name = "Screw"
+	// + products[1].sku = 4
+	// - title = "old"
+	// + title = "new"
+}
+
+func TestDiff(t *testing.T) {
+	a, _ := ParseString(example)
+	b, _ := ParseString(example)
+	if changes := Diff(a, b); len(changes) != 0 {
+		t.Log("Diff of equal documents:", changes)
+		t.Fail()
+	}
+
+	b.Set("database.ports[0]", 9000)
+	b.Set("database.enabled", "yes")
+	b.Delete("products[1]")
+	b.Set("owner.dob", a.GetDatetime("owner.dob", time.Time{}).UTC())
+	b.Set("owner.weight", 7.5)
+
+	checks := []struct {
+		typ  ChangeType
+		path string
+		old  Kind
+		new  Kind
+	}{
+		{ChangeModified, "database.enabled", Bool, String},
+		{ChangeModified, "database.ports", Array, Array},
+		{ChangeAdded, "owner.weight", Invalid, Float},
+		{ChangeAdded, "products[1].color", Invalid, String},
+		{ChangeAdded, "products[1].name", Invalid, String},
+		{ChangeAdded, "products[1].sku", Invalid, Integer},
+		{ChangeRemoved, "products[2]", Table, Invalid},
+	}
+	changes := Diff(a, b)
+	if len(changes) != len(checks) {
+		t.Log("Diff:", changes)
+		t.Fail()
+		return
+	}
+	for i, c := range checks {
+		got := changes[i]
+		if got.Type != c.typ || got.Path != c.path || got.Old.Kind() != c.old || got.New.Kind() != c.new {
+			t.Log("change", i, "is", got.Type, got.Path, got.Old.Kind(), got.New.Kind(), "want", c)
+			t.Fail()
+		}
+	}
+}
+
+func TestDiffNil(t *testing.T) {
+	doc, _ := ParseString("title = \"TOML\"\n[owner]\nname = \"Tom\"")
+	added, removed := Diff(nil, doc), Diff(doc, nil)
+	if len(added) != 2 || added[0].Type != ChangeAdded || added[1].Path != "title" {
+		t.Log("a nil document should be empty, got", added)
+		t.Fail()
+	}
+	if len(removed) != 2 || removed[0].Type != ChangeRemoved {
+		t.Log("a nil document should be empty, got", removed)
+		t.Fail()
+	}
+	if changes := Diff(nil, nil); len(changes) != 0 {
+		t.Log("nil documents should be equal, got", changes)
+		t.Fail()
+	}
+}
@@ -0,0 +1,106 @@
+package fiptoml
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+//StopEach is returned by the function given to Each to stop reading, Each then
+//returns nil.
+var StopEach = errors.New("stop each")
+
+//Each reads a document from r and calls fn with every element of the array of
+//tables name at its top level, in order, as soon as the element is read, with
+//its sub-tables. Only the element is kept in memory, the rest of the document
+//is checked but not built.
+//
+//Each stops at the first syntax error, or the first error returned by fn other
+//than StopEach. Both are *ParseError, the errors of fn located at the header of
+//the element and unwrapping to the error of fn.
+func Each(r io.Reader, name string, fn func(t *Toml) error) (err error) {
+	e := &eacher{s: newStmtScanner(r), name: name, fn: fn}
+	err = e.run()
+	if err == StopEach {
+		err = nil
+	}
+	return
+}
+
+type eacher struct {
+	s    *stmtScanner
+	name string
+	fn   func(t *Toml) error
+
+	elem  *Toml //the element being read
+	table *Toml //where keys go, nil outside the elements
+	line  int   //position of the header of the element
+	col   int
+}
+
+func (e *eacher) run() (err error) {
+	for {
+		st, err := e.s.next()
+		if err == io.EOF {
+			return e.yield()
+		}
+		if err != nil {
+			return err
+		}
+
+		idx := 0
+		switch st.kind {
+		case stmtTable, stmtTableArray:
+			isArray := st.kind == stmtTableArray
+			skip := 1
+			if isArray {
+				skip = 2
+			}
+			var header string
+			header, idx, err = extractTableName(st.text[skip:], isArray)
+			idx += skip
+			if err != nil {
+				break
+			}
+
+			switch {
+			case header == e.name && isArray:
+				if err = e.yield(); err != nil {
+					return err
+				}
+				e.elem, e.line, e.col = NewToml(), st.line, st.col
+				e.table = e.elem
+			case e.elem != nil && strings.HasPrefix(header, e.name+"."):
+				e.table, err = openTable(e.elem, header[len(e.name)+1:], isArray)
+				idx = 0
+			default:
+				if err = e.yield(); err != nil {
+					return err
+				}
+			}
+		default:
+			if e.table != nil {
+				idx, err = extractKeyValue(st.text, e.table)
+			} else {
+				_, idx, err = scanKeyValue(st.text)
+			}
+		}
+		if err != nil {
+			return e.s.fail(st, idx, err)
+		}
+	}
+}
+
+//yield passes the element read, if any, to fn
+func (e *eacher) yield() error {
+	elem := e.elem
+	e.elem, e.table = nil, nil
+	if elem == nil {
+		return nil
+	}
+	err := e.fn(elem)
+	if err != nil && err != StopEach {
+		return &ParseError{e.s.file, e.line, e.col, err}
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const eachExample = `title = "export"
+
+[[records]]
+id = 1
+[records.owner]
+name = "Tom"
+
+[meta]
+count = 3
+
+[[records]]
+id = 2
+[[records.parts]]
+sku = 10
+[[records.parts]]
+sku = 11
+
+[[records]]
+id = 3
+`
+
+func TestEach(t *testing.T) {
+	var ids []int
+	err := Each(strings.NewReader(eachExample), "records", func(record *Toml) error {
+		ids = append(ids, record.GetInt("id", 0))
+		switch record.GetInt("id", 0) {
+		case 1:
+			if record.GetString("owner.name", "") != "Tom" {
+				t.Log("sub-tables should belong to the element")
+				t.Fail()
+			}
+		case 2:
+			if record.GetInt("parts[1].sku", 0) != 11 || record.Has("count") {
+				t.Log("arrays of sub-tables should belong to the element")
+				t.Fail()
+			}
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 3 || ids[2] != 3 {
+		t.Log("every element should be read, got", ids, err)
+		t.Fail()
+	}
+}
+
+func TestEachStop(t *testing.T) {
+	count := 0
+	err := Each(strings.NewReader(eachExample+"[broken\n"), "records", func(record *Toml) error {
+		count++
+		return StopEach
+	})
+	if err != nil || count != 1 {
+		t.Log("StopEach should stop before the syntax error, got", count, err)
+		t.Fail()
+	}
+
+	errTooBig := errors.New("too big")
+	err = Each(strings.NewReader(eachExample), "records", func(record *Toml) error {
+		if record.GetInt("id", 0) == 2 {
+			return errTooBig
+		}
+		return nil
+	})
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, errTooBig) || pe.Line != 11 || pe.Col != 1 {
+		t.Log("the error should be located at the header of the element, got", err)
+		t.Fail()
+	}
+
+	err = Each(strings.NewReader("[[records]]\nid = 1\nid = 2\n"), "records", func(record *Toml) error {
+		t.Log("an element with an error should not be passed")
+		t.Fail()
+		return nil
+	})
+	if !errors.As(err, &pe) || pe.Line != 3 {
+		t.Log("a syntax error should be located, got", err)
+		t.Fail()
+	}
+}
+
+func ExampleEach() {
+	total := 0
+	err := Each(strings.NewReader(eachExample), "records", func(record *Toml) error {
+		total += record.GetInt("id", 0)
+		return nil
+	})
+	fmt.Println(total, err)
+	// Output:
+	// 6 <nil>
+}
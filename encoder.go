@@ -0,0 +1,389 @@
+package fiptoml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//KeyOrder tells the Encoder in which order to write the keys of tables.
+type KeyOrder int
+
+const (
+	KeysSorted   KeyOrder = iota
+	KeysInserted          //the order the keys were read or set in
+)
+
+//StringStyle tells the Encoder how to quote strings.
+type StringStyle int
+
+const (
+	//"basic", escaped as needed
+	StringBasic StringStyle = iota
+	//'literal', for strings without quotes, line breaks or control characters,
+	//basic otherwise
+	StringLiteral
+	//"""multi-line""", for strings holding line breaks, basic otherwise
+	StringMultiline
+)
+
+//EncodeOptions configures the format of an Encoder. The zero value is the
+//format of Encode.
+type EncodeOptions struct {
+	//Indent is written before the headers of tables once per level of nesting,
+	//and before keys once more than before their header:
+	//
+	//	[servers]
+	//	  [servers.alpha]
+	//	    ip = "10.0.0.1"
+	//	[[products]]
+	//	  name = "Hammer"
+	Indent string
+	//AlignEquals aligns the '=' of the values of a table
+	AlignEquals bool
+	//ArrayWidth wraps the arrays whose line would be longer, one element per
+	//line followed by a comma. 0 never wraps.
+	ArrayWidth int
+	Strings    StringStyle
+	//TableSpacing is the number of blank lines before headers, 1 by default,
+	//negative for none
+	TableSpacing int
+	Keys         KeyOrder
+	//Less orders the keys of tables when set, taking precedence over Keys.
+	//The values of a table always come before its tables.
+	Less func(a, b string) bool
+}
+
+var errNilDocument = errors.New("nil document")
+
+//Encoder writes documents to a stream as TOML.
+type Encoder struct {
+	w    io.Writer
+	opts EncodeOptions
+}
+
+//NewEncoder returns an Encoder writing to w with the zero value of
+//EncodeOptions.
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWith(w, nil)
+}
+
+//NewEncoderWith returns an Encoder writing to w in the format given by opts.
+//A nil opts is the zero value.
+func NewEncoderWith(w io.Writer, opts *EncodeOptions) *Encoder {
+	e := &Encoder{w: w}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.TableSpacing == 0 {
+		e.opts.TableSpacing = 1
+	}
+	return e
+}
+
+//Encode writes the document: the values of the top level first, then every
+//table under a header naming its full path, keys in order. Strings are
+//escaped as needed, and floats always have a fraction. A nil document is an
+//error, nothing being written.
+func (e *Encoder) Encode(doc *Toml) error {
+	_, err := e.encode(doc)
+	return err
+}
+
+func (e *Encoder) encode(doc *Toml) (int64, error) {
+	if doc == nil {
+		return 0, errNilDocument
+	}
+	enc := encoder{opts: &e.opts}
+	enc.table(doc, nil)
+	n, err := e.w.Write(enc.buf.Bytes())
+	return int64(n), err
+}
+
+//WriteTo writes the document to w, see Encoder.Encode. It implements
+//io.WriterTo.
+func (t *Toml) WriteTo(w io.Writer) (n int64, err error) {
+	return NewEncoder(w).encode(t)
+}
+
+//Marshal returns the document as TOML, see Encoder.Encode.
+func (t *Toml) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//String returns the document as TOML, see Encoder.Encode. A nil document is
+//an empty string.
+func (t *Toml) String() string {
+	b, _ := t.Marshal()
+	return string(b)
+}
+
+type encoder struct {
+	opts *EncodeOptions
+	buf  bytes.Buffer
+}
+
+//table writes the values of the table, then its tables and arrays of tables.
+//keys is the path of the table, the elements of arrays of tables being named
+//after the array.
+func (e *encoder) table(t *Toml, keys []string) {
+	var values, tables []string
+	for _, key := range e.keys(t) {
+		switch v := t.dict[key].(type) {
+		case *Toml:
+			tables = append(tables, key)
+		case []*Toml:
+			if len(v) > 0 {
+				tables = append(tables, key)
+			} else {
+				values = append(values, key)
+			}
+		default:
+			values = append(values, key)
+		}
+	}
+
+	indent := strings.Repeat(e.opts.Indent, len(keys))
+	width := 0
+	if e.opts.AlignEquals {
+		for _, key := range values {
+			width = max(width, utf8.RuneCountInString(encodeKey(key)))
+		}
+	}
+	for _, key := range values {
+		e.keyValue(indent, key, width, t.dict[key])
+	}
+
+	for _, key := range tables {
+		path := append(keys[:len(keys):len(keys)], key)
+		switch v := t.dict[key].(type) {
+		case *Toml:
+			//a table holding tables only is implied by their headers
+			if hasValues(v) {
+				e.header(path, false)
+			}
+			e.table(v, path)
+		case []*Toml:
+			for _, elem := range v {
+				e.header(path, true)
+				e.table(elem, path)
+			}
+		}
+	}
+}
+
+func (e *encoder) keys(t *Toml) []string {
+	switch {
+	case e.opts.Less != nil:
+		keys := t.sortedKeys()
+		sort.SliceStable(keys, func(i, j int) bool { return e.opts.Less(keys[i], keys[j]) })
+		return keys
+	case e.opts.Keys == KeysInserted:
+		return t.orderedKeys()
+	default:
+		return t.sortedKeys()
+	}
+}
+
+//hasValues tells whether the table has values other than tables, or is empty
+func hasValues(t *Toml) bool {
+	for _, v := range t.dict {
+		switch v := v.(type) {
+		case *Toml:
+		case []*Toml:
+			if len(v) == 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return len(t.dict) == 0
+}
+
+func (e *encoder) header(keys []string, isArray bool) {
+	if e.buf.Len() > 0 {
+		for i := 0; i < e.opts.TableSpacing; i++ {
+			e.buf.WriteByte('\n')
+		}
+	}
+	open, close := "[", "]"
+	if isArray {
+		open, close = "[[", "]]"
+	}
+	e.buf.WriteString(strings.Repeat(e.opts.Indent, len(keys)-1))
+	e.buf.WriteString(open)
+	for i, key := range keys {
+		if i > 0 {
+			e.buf.WriteByte('.')
+		}
+		e.buf.WriteString(encodeKey(key))
+	}
+	e.buf.WriteString(close + "\n")
+}
+
+//keyValue writes the key padded to width
+func (e *encoder) keyValue(indent, key string, width int, v interface{}) {
+	k := encodeKey(key)
+	if pad := width - utf8.RuneCountInString(k); pad > 0 {
+		k += strings.Repeat(" ", pad)
+	}
+	line := indent + k + " = "
+	e.buf.WriteString(line)
+	e.buf.WriteString(e.value(v, indent, utf8.RuneCountInString(line)))
+	e.buf.WriteByte('\n')
+}
+
+//value formats v, an array starting at the column col of a line indented
+//with indent
+func (e *encoder) value(v interface{}, indent string, col int) string {
+	switch x := v.(type) {
+	case string:
+		return e.string(x)
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		return encodeFloat(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case []*Toml:
+		//only empty arrays of tables are written as values
+		return "[]"
+	}
+
+	array := (Value{v}).Array()
+	if len(array) == 0 {
+		return "[]"
+	}
+	elems := make([]string, len(array))
+	for i, elem := range array {
+		elems[i] = e.value(elem.raw, indent, 0)
+	}
+	line := "[ " + strings.Join(elems, ", ") + " ]"
+	if e.opts.ArrayWidth <= 0 || col+utf8.RuneCountInString(line) <= e.opts.ArrayWidth {
+		return line
+	}
+
+	step := e.opts.Indent
+	if len(step) == 0 {
+		step = "    "
+	}
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, elem := range elems {
+		b.WriteString(indent + step + elem + ",\n")
+	}
+	b.WriteString(indent + "]")
+	return b.String()
+}
+
+func (e *encoder) string(s string) string {
+	switch {
+	case e.opts.Strings == StringLiteral && isLiteral(s):
+		return "'" + s + "'"
+	case e.opts.Strings == StringMultiline && strings.IndexByte(s, '\n') >= 0:
+		return encodeMultiString(s)
+	default:
+		return encodeString(s)
+	}
+}
+
+//isLiteral tells whether s can be written as a literal string
+func isLiteral(s string) bool {
+	for _, r := range s {
+		if r == '\'' || r < 0x20 && r != '\t' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+//encodeKey quotes keys which are not bare keys
+func encodeKey(key string) string {
+	if len(key) == 0 {
+		return `""`
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '-') {
+			return encodeString(key)
+		}
+	}
+	return key
+}
+
+//encodeString writes s as a basic string
+func encodeString(s string) string {
+	return `"` + escapeString(s, false) + `"`
+}
+
+//encodeMultiString writes s as a multi-line basic string, the line break after
+//the opening quotes being ignored by readers
+func encodeMultiString(s string) string {
+	return `"""` + "\n" + escapeString(s, true) + `"""`
+}
+
+//escapeString escapes s for a basic string, keeping line breaks in multi-line
+//strings
+func escapeString(s string, multi bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			if multi {
+				b.WriteByte('\n')
+			} else {
+				b.WriteString(`\n`)
+			}
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+//floats keep a fraction, so that they are read back as floats
+func encodeFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if strings.IndexByte(s, '.') < 0 {
+		s += ".0"
+	}
+	return s
+}
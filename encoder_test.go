@@ -0,0 +1,340 @@
+package fiptoml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	toml := NewToml()
+	toml.Set("title", "say \"hi\"\n\tbye \\ \x01")
+	toml.Set("ratio", 2.0)
+	toml.Set("count", 3)
+	toml.Set("ok", true)
+	toml.Set("tags", []string{"a", "b"})
+	toml.Set("empty", []int{})
+	toml.Set("dob", time.Date(1979, 5, 27, 7, 32, 0, 500, time.UTC))
+	toml.Set("servers.alpha.ip", "10.0.0.1")
+	toml.Set("servers.alpha.dc", "eu")
+	toml.Set("products[0].name", "Hammer")
+	toml.Set("products[0].dims.width", 1.5)
+	toml.Set("products[1].name", "Nail")
+	toml.Set("site.\"my key\"", "x")
+
+	expected := `count = 3
+dob = 1979-05-27T07:32:00.0000005Z
+empty = []
+ok = true
+ratio = 2.0
+tags = [ "a", "b" ]
+title = "say \"hi\"\n\tbye \\ \u0001"
+
+[[products]]
+name = "Hammer"
+
+[products.dims]
+width = 1.5
+
+[[products]]
+name = "Nail"
+
+[servers.alpha]
+dc = "eu"
+ip = "10.0.0.1"
+
+[site]
+"my key" = "x"
+`
+	b, err := toml.Marshal()
+	if err != nil || string(b) != expected {
+		t.Logf("expected:\n%s\ngot:\n%s\nerr: %v", expected, b, err)
+		t.Fail()
+	}
+	if toml.String() != expected {
+		t.Log("String should be the same as Marshal")
+		t.Fail()
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	toml, _ := ParseString(example)
+	b, err := toml.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(b)
+	if err != nil {
+		t.Fatal("the output should parse. err:", err, "\n", string(b))
+	}
+	if !Equal(toml, again) {
+		t.Log("the output should parse to the same document:\n", FormatDiff(Diff(toml, again)))
+		t.Fail()
+	}
+}
+
+type failingWriter struct {
+	n int
+}
+
+var errFailingWriter = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, errFailingWriter
+	}
+	return len(p), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	toml, _ := ParseString("title = \"TOML\"\nport = 80")
+	var buf bytes.Buffer
+	n, err := toml.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) || buf.String() != "port = 80\ntitle = \"TOML\"\n" {
+		t.Log("WriteTo should write the document, got", n, err, buf.String())
+		t.Fail()
+	}
+
+	n, err = toml.WriteTo(&failingWriter{5})
+	if err != errFailingWriter || n != 5 {
+		t.Log("WriteTo should report the errors of the writer, got", n, err)
+		t.Fail()
+	}
+	if err = NewEncoder(&failingWriter{}).Encode(toml); err != errFailingWriter {
+		t.Log("Encode should report the errors of the writer, got", err)
+		t.Fail()
+	}
+}
+
+func ExampleEncoder() {
+	toml := NewToml()
+	toml.Set("title", "TOML")
+	toml.Set("owner.name", "Tom")
+	toml.Set("servers[0].port", 8080)
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(toml); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(buf.String())
+	// Output:
+	// title = "TOML"
+	//
+	// [owner]
+	// name = "Tom"
+	//
+	// [[servers]]
+	// port = 8080
+}
+
+const formatExample = `title = "TOML"
+description = "first line\nsecond line"
+path = 'C:\Users'
+ports = [ 8001, 8002, 8003 ]
+
+[servers]
+
+[servers.alpha]
+ip = "10.0.0.1"
+dc = "eu"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+`
+
+func TestEncodeOptions(t *testing.T) {
+	toml, _ := ParseString(formatExample)
+	opts := &EncodeOptions{
+		Indent:      "  ",
+		AlignEquals: true,
+		ArrayWidth:  20,
+		Strings:     StringMultiline,
+		Keys:        KeysInserted,
+	}
+	expected := `title       = "TOML"
+description = """
+first line
+second line"""
+path        = "C:\\Users"
+ports       = [
+  8001,
+  8002,
+  8003,
+]
+
+  [servers.alpha]
+    ip = "10.0.0.1"
+    dc = "eu"
+
+[[products]]
+  name = "Hammer"
+  sku  = 738594937
+`
+	var buf bytes.Buffer
+	if err := NewEncoderWith(&buf, opts).Encode(toml); err != nil || buf.String() != expected {
+		t.Logf("expected:\n%s\ngot:\n%s\nerr: %v", expected, buf.String(), err)
+		t.Fail()
+	}
+	again, err := Parse(buf.Bytes())
+	if err != nil || !Equal(toml, again) {
+		t.Log("the output should parse to the same document, err:", err)
+		t.Fail()
+	}
+
+	opts = &EncodeOptions{Strings: StringLiteral, TableSpacing: -1,
+		Less: func(a, b string) bool { return len(a) < len(b) }}
+	expected = `path = 'C:\Users'
+ports = [ 8001, 8002, 8003 ]
+title = 'TOML'
+description = "first line\nsecond line"
+[servers.alpha]
+dc = 'eu'
+ip = '10.0.0.1'
+[[products]]
+sku = 738594937
+name = 'Hammer'
+`
+	buf.Reset()
+	if err := NewEncoderWith(&buf, opts).Encode(toml); err != nil || buf.String() != expected {
+		t.Logf("expected:\n%s\ngot:\n%s\nerr: %v", expected, buf.String(), err)
+		t.Fail()
+	}
+	again, err = Parse(buf.Bytes())
+	if err != nil || !Equal(toml, again) {
+		t.Log("the output should parse to the same document, err:", err)
+		t.Fail()
+	}
+}
+
+func TestEncodeInsertionOrder(t *testing.T) {
+	toml := NewToml()
+	toml.Set("zeta", 1)
+	toml.Set("alpha", 2)
+	toml.Set("mid", 3)
+	toml.Delete("zeta")
+	toml.Set("zeta", 4)
+
+	var buf bytes.Buffer
+	NewEncoderWith(&buf, &EncodeOptions{Keys: KeysInserted}).Encode(toml.Clone())
+	if buf.String() != "alpha = 2\nmid = 3\nzeta = 4\n" {
+		t.Log("keys should be written in the order they were set, got", buf.String())
+		t.Fail()
+	}
+}
+
+func TestEncodeInsertionOrderTrimmed(t *testing.T) {
+	toml := NewToml()
+	toml.Set("alpha", 1)
+	toml.Set("mid", 2)
+	toml.Set("zeta", 3)
+	for i := 0; i < 1000; i++ {
+		toml.Delete("mid")
+		toml.Set("mid", i)
+	}
+	if len(toml.order) > 20 {
+		t.Log("the keys removed should be dropped from the order, got", len(toml.order))
+		t.Fail()
+	}
+
+	var buf bytes.Buffer
+	NewEncoderWith(&buf, &EncodeOptions{Keys: KeysInserted}).Encode(toml)
+	if buf.String() != "alpha = 1\nzeta = 3\nmid = 999\n" {
+		t.Log("keys should be written in the order they were set, got", buf.String())
+		t.Fail()
+	}
+}
+
+func TestEncodeNil(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(nil); err == nil || buf.Len() != 0 {
+		t.Log("a nil document should be an error, got", err, buf.String())
+		t.Fail()
+	}
+	var toml *Toml
+	if _, err := toml.WriteTo(&buf); err == nil || buf.Len() != 0 {
+		t.Log("a nil document should be an error, got", err, buf.String())
+		t.Fail()
+	}
+	if b, err := toml.Marshal(); err == nil || b != nil {
+		t.Log("a nil document should be an error, got", err, string(b))
+		t.Fail()
+	}
+	if s := toml.String(); s != "" {
+		t.Log("a nil document should be an empty string, got", s)
+		t.Fail()
+	}
+}
+
+func TestEncodeQuotedKeys(t *testing.T) {
+	toml, err := ParseString(`127.0.0.1 = "localhost"
+"a b" = 1
+"" = 2
+'C:\temp' = 3
+"tab\there" = 4
+
+[srv."x y"]
+"a.b" = 5
+
+[[srv.'z.w']]
+"" = 6
+`)
+	if err != nil {
+		t.Fatal("quoted keys should be read. err:", err)
+	}
+	for key, expected := range map[string]int{`"a b"`: 1, `""`: 2, `'C:\temp'`: 3, `"tab\there"`: 4,
+		`srv."x y"."a.b"`: 5, `srv.'z.w'[0].""`: 6} {
+		if toml.GetInt(key, 0) != expected {
+			t.Log(key, "should be", expected, "got", toml.GetInt(key, 0))
+			t.Fail()
+		}
+	}
+	if toml.GetString(`"127.0.0.1"`, "") != "localhost" {
+		t.Log("bare keys should be kept whole")
+		t.Fail()
+	}
+
+	doc := toml
+	for i := 0; i < 2; i++ {
+		out, err := doc.Marshal()
+		if err != nil {
+			t.Fatal("Marshal should work. err:", err)
+		}
+		if doc, err = Parse(out); err != nil || !Equal(doc, toml) {
+			t.Logf("the output should read back the same, err: %v\n%s", err, out)
+			t.Fail()
+			return
+		}
+	}
+
+	for _, input := range []string{`"a = 1`, `"a"b = 1`, `"\x" = 1`, `["a]`, `[a."b"c]`, `[a.]`} {
+		if _, err := ParseString(input + "\n"); err == nil {
+			t.Logf("%q: should fail", input)
+			t.Fail()
+		}
+	}
+}
+
+func TestEncodeSpecialFloats(t *testing.T) {
+	toml := NewToml()
+	toml.Set("nan", math.NaN())
+	toml.Set("inf", math.Inf(1))
+	toml.Set("floats", []float64{math.Inf(-1), math.NaN(), 1})
+	out, err := toml.Marshal()
+	if err != nil {
+		t.Fatal("Marshal should work. err:", err)
+	}
+	again, err := Parse(out)
+	if err != nil || !Equal(again, toml) {
+		t.Logf("nan and inf should read back, err: %v\n%s", err, out)
+		t.Fail()
+	}
+	if v, _ := ParseString("a = -nan\n"); v == nil || !math.IsNaN(v.GetFloat("a", 0)) {
+		t.Log("-nan should be read as NaN")
+		t.Fail()
+	}
+}
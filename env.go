@@ -0,0 +1,121 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errUnterminatedVar = errors.New("unterminated ${")
+
+func errVarNotSet(name string) error {
+	return errors.New(fmt.Sprint("${", name, "} is not set"))
+}
+
+func errVarRequired(name, message string) error {
+	return errors.New(fmt.Sprint("${", name, "}: ", message))
+}
+
+func errNotScalar(name string, val interface{}) error {
+	return errors.New(fmt.Sprint("${", name, "} is ", typeName(val), ", only a whole value may reference it"))
+}
+
+func errExpand(path []pathSegment, err error) error {
+	return errors.New(fmt.Sprint(formatPath(path), ": ", err))
+}
+
+//lookupFunc finds the value of a variable, ok is false when it is not set
+type lookupFunc func(name string) (val interface{}, ok bool, err error)
+
+func envLookup(lookup func(string) (string, bool)) lookupFunc {
+	return func(name string) (interface{}, bool, error) {
+		val, ok := lookup(name)
+		return val, ok, nil
+	}
+}
+
+func expand(s string, lookup func(string) (string, bool)) (string, error) {
+	val, err := expandValue(s, envLookup(lookup), false)
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+//expandValue expands the variables of s. When whole is set and s is a single
+//variable, its value is returned with its own type, otherwise the values are
+//written into the string and must be single values.
+func expandValue(s string, lookup lookupFunc, whole bool) (interface{}, error) {
+	if strings.IndexByte(s, '$') < 0 {
+		return s, nil
+	}
+	if whole && strings.HasPrefix(s, "${") && strings.IndexByte(s, '}') == len(s)-1 {
+		return expandVar(s[2:len(s)-1], lookup)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return nil, errUnterminatedVar
+			}
+			expr := s[i+2 : i+2+end]
+			val, err := expandVar(expr, lookup)
+			if err != nil {
+				return nil, err
+			}
+			switch kindOf(val) {
+			case String:
+				b.WriteString(val.(string))
+			case Integer, Float, Bool, Datetime:
+				b.WriteString(wrapVal(val))
+			default:
+				return nil, errNotScalar(expr, val)
+			}
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+//expr is what is between the braces
+func expandVar(expr string, lookup lookupFunc) (interface{}, error) {
+	name, op, arg := expr, "", ""
+	if c := strings.Index(expr, ":-"); c >= 0 {
+		name, op, arg = expr[:c], ":-", expr[c+2:]
+	} else if c := strings.Index(expr, ":?"); c >= 0 {
+		name, op, arg = expr[:c], ":?", expr[c+2:]
+	}
+	if len(name) == 0 {
+		return nil, errVarNotSet(name)
+	}
+
+	val, ok, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	empty := !ok || val == ""
+	switch {
+	case op == ":-" && empty:
+		return arg, nil
+	case op == ":?" && empty:
+		if len(arg) == 0 {
+			return nil, errVarNotSet(name)
+		}
+		return nil, errVarRequired(name, arg)
+	case !ok:
+		return nil, errVarNotSet(name)
+	}
+	return val, nil
+}
@@ -0,0 +1,110 @@
+package fiptoml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lookupMap(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	lookup := lookupMap(map[string]string{"HOST": "db.local", "USER": "admin", "EMPTY": ""})
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"${HOST}:5432", "db.local:5432"},
+		{"${USER}@${HOST}", "admin@db.local"},
+		{"${PORT:-5432}", "5432"},
+		{"${EMPTY:-none}", "none"},
+		{"${HOST:-localhost}", "db.local"},
+		{"${HOST:?host required}", "db.local"},
+		{"$$HOME costs $5 $", "$HOME costs $5 $"},
+	}
+	for _, c := range cases {
+		if val, err := expand(c.input, lookup); err != nil || val != c.expected {
+			t.Logf("%q: expected %q, got %q err: %v", c.input, c.expected, val, err)
+			t.Fail()
+		}
+	}
+
+	invalid := map[string]string{
+		"${MISSING}":          "${MISSING} is not set",
+		"${EMPTY:?set EMPTY}": "${EMPTY}: set EMPTY",
+		"${MISSING:?}":        "${MISSING} is not set",
+		"${HOST":              "unterminated ${",
+		"${}":                 "${} is not set",
+	}
+	for input, msg := range invalid {
+		if _, err := expand(input, lookup); err == nil || err.Error() != msg {
+			t.Logf("%q: expected %q, got %v", input, msg, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestParseWithExpandEnv(t *testing.T) {
+	input := `[database]
+password = "${DB_PASSWORD}"
+hosts = [ "${HOST}", "backup" ]
+
+[[servers]]
+url = "http://${HOST}:${PORT:-80}"
+`
+	opts := &Options{ExpandEnv: true, LookupEnv: lookupMap(map[string]string{"DB_PASSWORD": "s3cret", "HOST": "a.local"})}
+	toml, err := ParseWith([]byte(input), opts)
+	if err != nil {
+		t.Fatal("ParseWith should work. err:", err)
+	}
+	if toml.GetString("database.password", "") != "s3cret" ||
+		toml.GetStringArray("database.hosts")[0] != "a.local" ||
+		toml.GetString("servers[0].url", "") != "http://a.local:80" {
+		t.Log("variables should be expanded")
+		t.Fail()
+	}
+
+	toml, _ = ParseString(input)
+	if toml.GetString("database.password", "") != "${DB_PASSWORD}" {
+		t.Log("variables should not be expanded unless asked for")
+		t.Fail()
+	}
+
+	opts.LookupEnv = lookupMap(map[string]string{"DB_PASSWORD": "s3cret"})
+	_, err = ParseWith([]byte(input), opts)
+	if err == nil || err.Error() != "database.hosts[0]: ${HOST} is not set" {
+		t.Log("an unresolved variable should be reported with its path, got", err)
+		t.Fail()
+	}
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	os.WriteFile(path, []byte(input), 0644)
+	_, err = LoadWith(path, opts)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": database.hosts[0]") {
+		t.Log("the error should name the file, got", err)
+		t.Fail()
+	}
+}
+
+func ExampleParseWith() {
+	opts := &Options{ExpandEnv: true, LookupEnv: func(name string) (string, bool) {
+		return map[string]string{"DB_PASSWORD": "s3cret"}[name], name == "DB_PASSWORD"
+	}}
+	toml, err := ParseWith([]byte(`password = "${DB_PASSWORD}"
+user = "${DB_USER:-admin}"`), opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(toml.GetString("user", ""), toml.GetString("password", ""))
+	// Output:
+	// admin s3cret
+}
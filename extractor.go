@@ -1,14 +1,14 @@
 package fiptoml
 
 import (
-	"unicode/utf8"
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
-	"strings"
 	"time"
 	"unicode"
-	"errors"
-	"regexp"
+	"unicode/utf8"
 )
 
 
@@ -24,12 +24,9 @@ var (
 	errMultiString       = errors.New("invalid multi-line string")
 	errStringSyntaxError = errors.New("string syntax error")
 	errArray             = errors.New("Date types in an array should NOT be mixed")
-
-	multiLineSkipR = regexp.MustCompile(`\\[\n\r\t\f ]+`)
-	quoteLineR     = regexp.MustCompile(`\n`)
-	intR           = regexp.MustCompile(`^[+-]?(?:0|[1-9][0-9]*)$`)
-	floatR         = regexp.MustCompile(`-?(?:0|[1-9][0-9]*)\.[0-9]+$`)
-	datatimeR      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+	errTrailingContent   = errors.New("unexpected content after value")
+	errArraySyntax       = errors.New("array elements should be separated by commas")
+	errNestedArray       = errors.New("arrays of arrays are not supported")
 )
 
 func errDuplicatedKey(key string) error {
@@ -42,53 +39,66 @@ func errUnsupportedValue(key string) error {
 
 
 func extractTableArray(input []byte, doc *Toml) (idx int, err error) {
-	name, idx, err := extractTableName(input, true)
+	subDoc, idx, err := extractTableHeader(input, doc, true)
 	if err != nil {
 		return
 	}
+	delta, err := extractKeyValueSection(input[idx:], subDoc)
+	idx += delta
+	return
+}
 
-	//array, err :=doc.GetTableArray(name)
-	subDoc := NewToml()
-	delta := 0
-
-	switch array := doc.dict[name].(type) {
-	case nil:
-		doc.dict[name] = []*Toml{subDoc}
-	case []*Toml:
-		doc.dict[name] = append(array, subDoc)
-	default:
-		goto DupKey
+func extractTable(input []byte, doc *Toml) (idx int, err error) {
+	subDoc, idx, err := extractTableHeader(input, doc, false)
+	if err != nil {
+		return
 	}
-
-	delta, err = extractKeyValueSection(input[idx:], subDoc)
+	delta, err := extractKeyValueSection(input[idx:], subDoc)
 	idx += delta
 	return
+}
 
-DupKey:
-	err = errDuplicatedKey(name)
+//extractTableHeader reads the name of a table, or of an array of tables, after
+//its opening brackets and returns the table the following keys belong to.
+func extractTableHeader(input []byte, doc *Toml, isArray bool) (table *Toml, idx int, err error) {
+	name, idx, err := extractTableName(input, isArray)
+	if err != nil {
+		return
+	}
+	table, err = openTable(doc, name, isArray)
 	return
 }
 
-func extractTable(input []byte, doc *Toml) (idx int, err error) {
-	name, idx, err := extractTableName(input, false)
-
-	delta := 0
+//openTable returns the table name, creating it, or appends a new table to the
+//array of tables name.
+func openTable(doc *Toml, name string, isArray bool) (table *Toml, err error) {
+	parent, key, err := parentTable(doc, name)
 	if err != nil {
 		return
 	}
 
-	var subDoc *Toml
-	switch v := doc.dict[name].(type) {
+	switch v := parent.dict[key].(type) {
 	case nil:
-		subDoc = NewToml()
-		doc.dict[name] = subDoc
+		table = NewToml()
+		if isArray {
+			parent.set(key, []*Toml{table})
+		} else {
+			parent.set(key, table)
+		}
 	case *Toml:
-		subDoc = v
+		if isArray {
+			goto DupKey
+		}
+		table = v
+	case []*Toml:
+		if !isArray {
+			goto DupKey
+		}
+		table = NewToml()
+		parent.set(key, append(v, table))
 	default:
 		goto DupKey
 	}
-	delta, err = extractKeyValueSection(input[idx:], subDoc)
-	idx += delta
 	return
 
 DupKey:
@@ -96,6 +106,64 @@ DupKey:
 	return
 }
 
+//Table names with dots in them are nested tables, the intermediate tables are
+//created as needed and the last element of an array of tables is the parent of
+//its sub-tables.
+func parentTable(doc *Toml, name string) (parent *Toml, key string, err error) {
+	keys, err := tableKeys(name)
+	if err != nil {
+		return
+	}
+	parent, key = doc, keys[len(keys)-1]
+	for _, k := range keys[:len(keys)-1] {
+		switch v := parent.dict[k].(type) {
+		case nil:
+			sub := NewToml()
+			parent.set(k, sub)
+			parent = sub
+		case *Toml:
+			parent = v
+		case []*Toml:
+			parent = v[len(v)-1]
+		default:
+			err = errDuplicatedKey(name)
+			return
+		}
+	}
+	return
+}
+
+//tableKeys splits the name of a table at its dots into keys, bare or quoted
+func tableKeys(name string) (keys []string, err error) {
+	in := []byte(name)
+	for i := 0; ; i++ {
+		var key string
+		if i < len(in) && (in[i] == '"' || in[i] == '\'') {
+			var n int
+			if key, n, err = quotedKey(in[i:]); err != nil {
+				return nil, err
+			}
+			i += n
+		} else {
+			from := i
+			for i < len(in) && in[i] != '.' {
+				i++
+			}
+			if i == from {
+				return nil, errInvalidTableKey
+			}
+			key = name[from:i]
+		}
+		keys = append(keys, key)
+		if i == len(in) {
+			return
+		}
+		if in[i] != '.' {
+			return nil, errInvalidTableKey
+		}
+	}
+}
+
 func extractKeyValueSection(input []byte, doc *Toml) (idx int, err error) {
 	for idx < len(input) {
 		shouldEnd, delta := isSectionEnd(input[idx:])
@@ -111,47 +179,102 @@ func extractKeyValueSection(input []byte, doc *Toml) (idx int, err error) {
 	return
 }
 
+//keyValue is a key and its value, from and to delimit the text of the value
+type keyValue struct {
+	key      string
+	val      interface{}
+	from, to int
+}
+
 func extractKeyValue(input []byte, doc *Toml) (idx int, err error) {
-	key, idx := extractKey(input)
-	if idx == 0 {
-		err = errEmptyKey
-	} else if doc.dict[key] != nil {
-		err = errDuplicatedKey(key)
-	} else {
-		idx += skipSpaceAndEquals(input[idx:])
-		val, delta, err := extractValue(input[idx:])
-		if err != nil {
-			return idx, err
+	kv, idx, err := scanKeyValue(input)
+	if err != nil {
+		return
+	}
+	if doc.dict[kv.key] != nil {
+		return 0, errDuplicatedKey(kv.key)
+	}
+	doc.set(kv.key, kv.val)
+	return
+}
+
+//scanKeyValue reads a key and its value up to the end of the line, idx is the
+//offset of the error on failure
+func scanKeyValue(input []byte) (kv keyValue, idx int, err error) {
+	if kv.key, idx, err = extractKey(input); err != nil {
+		return
+	}
+	idx += skipSpaceAndEquals(input[idx:])
+	kv.from = idx
+	val, delta, err := extractValue(input[idx:])
+	if err != nil {
+		return kv, idx + delta, err
+	}
+	if val == nil {
+		return kv, idx, errUnsupportedValue(kv.key)
+	}
+	kv.val = val
+	idx += delta
+	kv.to = idx
+
+	//only spaces and a comment may follow
+	end := idx + skipRight(input[idx:])
+	if rest := idx + skipLeft(input[idx:end]); rest < end {
+		return kv, rest, errTrailingContent
+	}
+	return kv, end, nil
+}
+
+//extractKey reads a bare key up to the first space or equals sign, or a quoted
+//key. idx is the offset of the error on failure.
+func extractKey(input []byte) (key string, idx int, err error) {
+	if len(input) > 0 && (input[0] == '"' || input[0] == '\'') {
+		if key, idx, err = quotedKey(input); err != nil {
+			return
 		}
-		idx += delta
-		idx += skipRight(input[idx:])
-		if val == nil {
-			err = errUnsupportedValue(key)
-			return idx, err
+		if idx < len(input) && input[idx] != ' ' && input[idx] != '\t' && input[idx] != '=' {
+			return "", idx, errInvalidKeyName
 		}
-		doc.dict[key] = val
+		return
 	}
-	return
+	for i, c := range input {
+		if c == ' ' || c == '\t' || c == '=' {
+			if i == 0 {
+				break
+			}
+			return string(input[:i]), i, nil
+		}
+	}
+	return "", 0, errEmptyKey
 }
 
-func extractKey(input []byte) (key string, idx int) {
-	i := 0
-L:
-	for i < len(input) {
-		r, w := utf8.DecodeRune(input[i:])
-		switch r {
-		case ' ', '\t', '=':
-			idx = i
-			key = string(input[:i])
-			break L
-		default:
-			i += w
+//quotedKey reads a basic or a literal key, on a single line. idx follows the
+//closing quote, or is the offset of the error on failure.
+func quotedKey(input []byte) (key string, idx int, err error) {
+	quote := input[0]
+	i := 1
+	for i < len(input) && input[i] != quote && input[i] != '\n' {
+		if quote == '"' && input[i] == '\\' {
+			i++
 		}
+		i++
 	}
-	return
+	if i >= len(input) || input[i] != quote {
+		return "", 0, errInvalidKeyName
+	}
+	if quote == '\'' {
+		return string(input[1:i]), i + 1, nil
+	}
+	if key, idx, err = unescape(input[1:i], false); err != nil {
+		return "", 1 + idx, err
+	}
+	return key, i + 1, nil
 }
 
 func extractValue(input []byte) (val interface{}, idx int, err error) {
+	if len(input) == 0 {
+		return
+	}
 	switch input[0] {
 	case '"', '\'':
 		val, idx, err = extractString(input)
@@ -159,7 +282,7 @@ func extractValue(input []byte) (val interface{}, idx int, err error) {
 		val, idx, err = extractBool(input)
 	case '[':
 		val, idx, err = extractArray(input)
-	case '+', '-', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+	case '+', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'i', 'n':
 		val, idx, err = extractNumber(input)
 	default:
 		val = nil
@@ -169,53 +292,160 @@ func extractValue(input []byte) (val interface{}, idx int, err error) {
 
 //single/multiple line string/literal
 func extractString(input []byte) (val string, idx int, err error) {
-	length := len(input)
-	if input[0] == '"' {
-		if length > 3 && input[1] == '"' && input[2] == '"' {
-			val, idx, err = extractMultiString(input)
-		} else {
-			val, idx, err = extractSingleString(input)
+	switch {
+	case hasPrefix(input, `"""`):
+		val, idx, err = extractMultiString(input)
+	case input[0] == '"':
+		val, idx, err = extractSingleString(input)
+	case hasPrefix(input, `'''`):
+		end := indexOf(input[3:], `'''`)
+		if end < 0 {
+			return "", 0, errMultiString
 		}
-	} else if input[0] == '\'' {
-		if length > 3 && input[1] == '\'' && input[2] == '\'' {
-			val, idx = extractStringUntil(input[3:], []byte{'\'', '\'', '\''})
-			idx += 3
-		} else {
-			val, idx = extractStringUntil(input[1:], []byte{'\''})
-			idx += 1
+		val, idx = string(trimFirstLineBreak(input[3:3+end])), 3+end+3
+	case input[0] == '\'':
+		end := 1
+		for end < len(input) && input[end] != '\'' && input[end] != '\n' {
+			end++
 		}
-	} else {
+		if end == len(input) || input[end] != '\'' {
+			return "", 0, errStringSyntaxError
+		}
+		val, idx = string(input[1:end]), end+1
+	default:
 		err = errStringSyntaxError
 	}
 	return
+}
+
+//unescape resolves the escapes of a basic string. In multi-line strings a
+//backslash ending a line trims the line break and the spaces that follow. idx
+//is the offset of an invalid escape.
+func unescape(in []byte, multi bool) (out string, idx int, err error) {
+	if bytes.IndexByte(in, '\\') < 0 {
+		return string(in), 0, nil
+	}
 
+	buf := make([]byte, 0, len(in))
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+		if i+1 == len(in) {
+			return "", i, errStringSyntaxError
+		}
+		switch in[i+1] {
+		case 'b':
+			buf = append(buf, '\b')
+		case 't':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'r':
+			buf = append(buf, '\r')
+		case '"':
+			buf = append(buf, '"')
+		case '\\':
+			buf = append(buf, '\\')
+		case 'u', 'U':
+			n := 4
+			if in[i+1] == 'U' {
+				n = 8
+			}
+			r, ok := parseHex(in[i+2:], n)
+			if !ok || !utf8.ValidRune(r) {
+				return "", i, errStringSyntaxError
+			}
+			buf = utf8.AppendRune(buf, r)
+			i += n
+		default:
+			j := i + 1
+			for j < len(in) && (in[j] == ' ' || in[j] == '\t') {
+				j++
+			}
+			if !multi || j == len(in) || (in[j] != '\n' && in[j] != '\r') {
+				return "", i, errStringSyntaxError
+			}
+			for j < len(in) && isBlank(in[j]) {
+				j++
+			}
+			i = j - 1
+			continue
+		}
+		i++
+	}
+	return string(buf), 0, nil
 }
 
-func unquote(in string) (out string, err error) {
-	if len(in) == 0 {
+func parseHex(in []byte, n int) (r rune, ok bool) {
+	if len(in) < n {
 		return
 	}
-
-	in = fmt.Sprintf("%c%s%c", '"', in, '"')
-	out, err = strconv.Unquote(in)
-	return
+	for _, c := range in[:n] {
+		switch {
+		case '0' <= c && c <= '9':
+			r = r<<4 | rune(c-'0')
+		case 'a' <= c && c <= 'f':
+			r = r<<4 | rune(c-'a'+10)
+		case 'A' <= c && c <= 'F':
+			r = r<<4 | rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
 }
 
 func extractMultiString(input []byte) (val string, idx int, err error) {
-	val, idx = extractStringUntil(input[3:], []byte{'"', '"', '"'})
-	idx += 3
-	val = multiLineSkipR.ReplaceAllString(val, "")
-	val = quoteLineR.ReplaceAllString(val, `\n`)
-	val = strings.Replace(val, `"`, `\"`, -1)
-	val, err = unquote(val)
-	return
+	end := 3
+	for ; end < len(input) && !hasPrefix(input[end:], `"""`); end++ {
+		if input[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(input) {
+		return "", 0, errMultiString
+	}
+	content := input[3:end]
+	trimmed := trimFirstLineBreak(content)
+	val, idx, err = unescape(trimmed, true)
+	if err != nil {
+		return "", 3 + len(content) - len(trimmed) + idx, err
+	}
+	return val, end + 3, nil
 }
 
 func extractSingleString(input []byte) (val string, idx int, err error) {
-	val, idx = extractStringUntil(input[1:], []byte{'"'})
-	val, err = unquote(val)
-	idx += 1
-	return
+	end := 1
+	for ; end < len(input) && input[end] != '"' && input[end] != '\n'; end++ {
+		if input[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(input) || input[end] != '"' {
+		return "", 0, errStringSyntaxError
+	}
+	val, idx, err = unescape(input[1:end], false)
+	if err != nil {
+		return "", 1 + idx, err
+	}
+	return val, end + 1, nil
+}
+
+//the line break right after the opening quotes of a multi-line string is not
+//part of it
+func trimFirstLineBreak(in []byte) []byte {
+	if hasPrefix(in, "\r\n") {
+		return in[2:]
+	}
+	if hasPrefix(in, "\n") {
+		return in[1:]
+	}
+	return in
 }
 
 func extractStringUntil(input []byte, arr []byte) (val string, idx int) {
@@ -256,135 +486,290 @@ ErrBool:
 
 //for int, float, datetime
 func extractNumber(input []byte) (val interface{}, idx int, err error) {
-	idx = skipUntilSpace(input)
-	str := string(input[0:idx])
-	if datatimeR.MatchString(str) {
-		val, err = time.Parse(time.RFC3339, str)
-	} else if intR.MatchString(str) {
-		val, err = strconv.Atoi(str)
-	} else if floatR.MatchString(str) {
-		val, err = strconv.ParseFloat(str, 64)
-	} else {
+	end := skipUntilSpace(input)
+	num := input[:end]
+	switch {
+	case isDatetime(num):
+		val, err = time.Parse(time.RFC3339, string(num))
+	case isInt(num):
+		var ok bool
+		if val, ok = parseInt(num); !ok {
+			err = errNumber
+		}
+	case isFloat(num):
+		val, err = strconv.ParseFloat(string(num), 64)
+	case isSpecialFloat(num):
+		val = specialFloat(num)
+	default:
 		err = errNumber
 	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, end, nil
+}
+
+//1979-05-27T07:32:00, an optional fraction of second, then Z or an offset
+func isDatetime(in []byte) bool {
+	const layout = "0000-00-00T00:00:00"
+	if len(in) <= len(layout) {
+		return false
+	}
+	for i := 0; i < len(layout); i++ {
+		if layout[i] == '0' && !isDigit(in[i]) || layout[i] != '0' && in[i] != layout[i] {
+			return false
+		}
+	}
+
+	rest := in[len(layout):]
+	if rest[0] == '.' {
+		n := countDigits(rest[1:])
+		if n == 0 {
+			return false
+		}
+		rest = rest[1+n:]
+	}
+	if len(rest) == 1 {
+		return rest[0] == 'Z'
+	}
+	return len(rest) == 6 && (rest[0] == '+' || rest[0] == '-') &&
+		countDigits(rest[1:3]) == 2 && rest[3] == ':' && countDigits(rest[4:]) == 2
+}
+
+//[+-]?(0|[1-9][0-9]*)
+func isInt(in []byte) bool {
+	if len(in) > 0 && (in[0] == '+' || in[0] == '-') {
+		in = in[1:]
+	}
+	n := countDigits(in)
+	return n == len(in) && (n == 1 || n > 1 && in[0] != '0')
+}
+
+//[+-]?(0|[1-9][0-9]*)\.[0-9]+
+func isFloat(in []byte) bool {
+	dot := bytes.IndexByte(in, '.')
+	return dot >= 0 && isInt(in[:dot]) && len(in[:dot]) > 0 &&
+		dot+1 < len(in) && countDigits(in[dot+1:]) == len(in)-dot-1
+}
+
+//inf and nan, signed or not
+func isSpecialFloat(in []byte) bool {
+	if len(in) > 0 && (in[0] == '+' || in[0] == '-') {
+		in = in[1:]
+	}
+	return string(in) == "inf" || string(in) == "nan"
+}
+
+func specialFloat(in []byte) float64 {
+	switch {
+	case bytes.HasSuffix(in, []byte("nan")):
+		return math.NaN()
+	case in[0] == '-':
+		return math.Inf(-1)
+	default:
+		return math.Inf(1)
+	}
+}
+
+//parseInt parses what isInt accepts, ok is false on overflow
+func parseInt(in []byte) (n int, ok bool) {
+	neg := in[0] == '-'
+	if in[0] == '+' || in[0] == '-' {
+		in = in[1:]
+	}
+	limit := uint64(math.MaxInt)
+	if neg {
+		limit++
+	}
+	var u uint64
+	for _, c := range in {
+		d := uint64(c - '0')
+		if u > (limit-d)/10 {
+			return 0, false
+		}
+		u = u*10 + d
+	}
+	if neg {
+		return int(-u), true
+	}
+	return int(u), true
+}
+
+func countDigits(in []byte) (n int) {
+	for n < len(in) && isDigit(in[n]) {
+		n++
+	}
 	return
 }
 
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+//arrays hold values of one type, they may span lines and end with a comma
 func extractArray(input []byte) (val interface{}, idx int, err error) {
-	from := 1 + skipLeft(input[1:])
-	idx = from + skipUntilChar(input[from:], ']')
-	str := string(input[from:idx])
-	idx += 1
-	vals := strings.Split(str, ",")
-
-	length := len(vals)
-	if length < 1 {
-		return
+	var array arrayBuilder
+	idx = 1
+	for {
+		idx += skipLeft(input[idx:])
+		if idx < len(input) && input[idx] == ']' {
+			break
+		}
+		delta, err := array.add(input[idx:])
+		if err != nil {
+			return nil, idx + delta, err
+		}
+
+		idx += delta
+		idx += skipLeft(input[idx:])
+		if idx < len(input) && input[idx] == ',' {
+			idx++
+			continue
+		}
+		if idx < len(input) && input[idx] == ']' {
+			break
+		}
+		return nil, idx, errArraySyntax
 	}
+	return array.value(), idx + 1, nil
+}
 
-	val0, _, err := extractValue([]byte(strings.TrimSpace(vals[0])))
-	switch v0 := val0.(type) {
-	case string:
-		arr := make([]string, length, length)
-		arr[0] = v0
-		for i := 1; i < length; i++ {
-			v, _, err := extractString([]byte(strings.TrimSpace(vals[i])))
-			if err != nil {
-				goto ErrArray
-			}
-			arr[i] = v
-		}
-		val = arr
-	case int:
-		arr := make([]int, length, length)
-		arr[0] = v0
-		for i := 1; i < length; i++ {
-			v, err := strconv.Atoi(strings.TrimSpace(vals[i]))
-			if err != nil {
-				goto ErrArray
-			}
-			arr[i] = v
-		}
-		val = arr
-	case float64:
-		arr := make([]float64, length, length)
-		arr[0] = v0
-		for i := 1; i < length; i++ {
-			v, err := strconv.ParseFloat(strings.TrimSpace(vals[i]), 64)
-			if err != nil {
-				goto ErrArray
+//arrayBuilder collects the elements of an array in a slice of their type
+type arrayBuilder struct {
+	kind    Kind
+	mixed   bool
+	strings []string
+	ints    []int
+	floats  []float64
+	bools   []bool
+	times   []time.Time
+}
+
+//add reads the next element, strings and bools are not boxed on the way
+func (a *arrayBuilder) add(input []byte) (idx int, err error) {
+	var elem interface{}
+	switch input[0] {
+	case '"', '\'':
+		var s string
+		if s, idx, err = extractString(input); err == nil && a.accept(String) {
+			a.strings = append(a.strings, s)
+		}
+	case 't', 'f':
+		var b bool
+		if b, idx, err = extractBool(input); err == nil && a.accept(Bool) {
+			a.bools = append(a.bools, b)
+		}
+	case '[':
+		return 0, errNestedArray
+	default:
+		if elem, idx, err = extractValue(input); elem == nil && err == nil {
+			err = errStringSyntaxError
+		}
+		switch e := elem.(type) {
+		case int:
+			if a.accept(Integer) {
+				a.ints = append(a.ints, e)
 			}
-			arr[i] = v
-		}
-		val = arr
-	case bool:
-		arr := make([]bool, length, length)
-		arr[0] = v0
-		for i := 1; i < length; i++ {
-			v, err := strconv.ParseBool(strings.TrimSpace(vals[i]))
-			if err != nil {
-				goto ErrArray
+		case float64:
+			if a.accept(Float) {
+				a.floats = append(a.floats, e)
 			}
-			arr[i] = v
-		}
-		val = arr
-	case time.Time:
-		arr := make([]time.Time, length, length)
-		arr[0] = v0
-		for i := 1; i < length; i++ {
-			v, err := time.Parse(time.RFC3339, strings.TrimSpace(vals[i]))
-			if err != nil {
-				goto ErrArray
+		case time.Time:
+			if a.accept(Datetime) {
+				a.times = append(a.times, e)
 			}
-			arr[i] = v
 		}
-		val = arr
 	}
-
-	return
-ErrArray:
-	err = errArray
+	if err == nil && a.mixed {
+		return 0, errArray
+	}
 	return
 }
 
+//accept tells whether an element of the kind may follow the ones before
+func (a *arrayBuilder) accept(kind Kind) bool {
+	if a.kind == Invalid {
+		a.kind = kind
+	}
+	a.mixed = a.mixed || a.kind != kind
+	return !a.mixed
+}
+
+//value returns the typed slice, nil for an empty array
+func (a *arrayBuilder) value() interface{} {
+	switch a.kind {
+	case String:
+		return a.strings
+	case Integer:
+		return a.ints
+	case Float:
+		return a.floats
+	case Bool:
+		return a.bools
+	case Datetime:
+		return a.times
+	default:
+		//an empty array has no type of elements
+		return []interface{}{}
+	}
+}
+
+//extractTableName reads the name of a table after its opening brackets, and
+//skips the rest of the line.
 func extractTableName(input []byte, isArray bool) (name string, idx int, err error) {
-	//todo: build the whole thing for multiple ...
-	i := 0
+	name, idx, err = scanTableName(input, isArray)
+	if err != nil {
+		return
+	}
+	idx += skipRight(input[idx:])
+	return
+}
+
+//scanTableName reads the name of a table after its opening brackets, the
+//whitespace around it being ignored. idx is right after the closing brackets.
+func scanTableName(input []byte, isArray bool) (name string, idx int, err error) {
+	from := 0
+	for from < len(input) && isSpace(rune(input[from])) {
+		from++
+	}
+	i := from
 L:
 	for i < len(input) {
 		r, w := utf8.DecodeRune(input[i:])
 		switch r {
-		case ']':
-			if i == 0 {
-				goto InvalidKey
-			}
-			r, w = utf8.DecodeRune(input[i+w:])
-			if (isArray && r == ']') ||
-					(!isArray && (unicode.IsSpace(r)) || r == '#') {
-				idx = i + w
-				name = string(input[:i])
-				break L
-			} else {
-				goto InvalidKey
+		case ']', ' ', '\t', '\n', '\f', '\r':
+			break L
+		case '"', '\'':
+			_, n, err := quotedKey(input[i:])
+			if err != nil {
+				return "", i + n, err
 			}
+			i += n
 		case utf8.RuneError:
-			goto InvalidUtf8
-		case ' ', '\t', '\n', '\f', '\r':
-			goto InvalidKey
+			return "", 0, errUtf8
 		default:
 			i += w
 		}
 	}
-	idx += skipRight(input[idx:])
-
-	return
-
-InvalidUtf8:
-	err = errUtf8
-	return
+	if i == from {
+		return "", 0, errInvalidTableKey
+	}
+	name = string(input[from:i])
 
-InvalidKey:
-	err = errInvalidTableKey
+	for i < len(input) && isSpace(rune(input[i])) {
+		i++
+	}
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+	if !bytes.HasPrefix(input[i:], []byte(closing)) {
+		return "", 0, errInvalidTableKey
+	}
+	idx = i + len(closing)
+	if r, _ := utf8.DecodeRune(input[idx:]); !isArray && !unicode.IsSpace(r) && r != '#' {
+		return "", 0, errInvalidTableKey
+	}
 	return
 }
 
@@ -397,15 +782,6 @@ func getTableValue(input []byte) (err error) {
 	return
 }
 
-func isLineEnd(r rune) bool {
-	switch r {
-	case '\n', '\r', '\f':
-		return true
-	default:
-		return false
-	}
-}
-
 func isSpace(r rune) bool {
 	switch r {
 	case ' ', '\t':
@@ -445,13 +821,20 @@ func isSectionEnd(input []byte) (bool, int) {
 func skipLeft(input []byte) (skip int) {
 	i := 0
 	for i < len(input) {
-		r, w := utf8.DecodeRune(input[i:])
-		if r == '#' {
+		c := input[i]
+		switch {
+		case c == '#':
 			i += skipComments(input[i:])
-		} else if unicode.IsSpace(r) {
-			i += w
-		} else {
+		case isBlank(c):
+			i++
+		case c < utf8.RuneSelf:
 			return i
+		default:
+			r, w := utf8.DecodeRune(input[i:])
+			if !unicode.IsSpace(r) {
+				return i
+			}
+			i += w
 		}
 	}
 	return i
@@ -459,32 +842,60 @@ func skipLeft(input []byte) (skip int) {
 
 //skip comments and spaces, and the line break after a value
 func skipRight(input []byte) (skip int) {
-	return skipUntil(input, isLineEnd, true)
+	i := skipComments(input)
+	if i < len(input) {
+		i++
+	}
+	return i
 }
 
 func skipComments(input []byte) int {
-	return skipUntil(input, isLineEnd, false)
+	for i, c := range input {
+		if c == '\n' || c == '\r' || c == '\f' {
+			return i
+		}
+	}
+	return len(input)
 }
 
 func skipSpaceAndEquals(input []byte) int {
-	return skipIf(input, func(r rune) bool {
-			switch r {
-			case ' ', '\t', '=':
-				return true
-			default:
-				return false
-			}
-		})
+	i := 0
+	for i < len(input) && (input[i] == ' ' || input[i] == '\t' || input[i] == '=') {
+		i++
+	}
+	return i
 }
 
+//the end of a number or a datetime
 func skipUntilSpace(input []byte) int {
-	return skipUntil(input, func(r rune) bool {
-			if r == '#' || unicode.IsSpace(r) {
-				return true
-			} else {
-				return false
-			}
-		}, false)
+	for i, c := range input {
+		if c == '#' || c == ',' || c == ']' || isBlank(c) {
+			return i
+		}
+	}
+	return len(input)
+}
+
+func isBlank(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	default:
+		return false
+	}
+}
+
+func hasPrefix(input []byte, prefix string) bool {
+	return len(input) >= len(prefix) && string(input[:len(prefix)]) == prefix
+}
+
+func indexOf(input []byte, sep string) int {
+	for i := 0; i+len(sep) <= len(input); i++ {
+		if string(input[i:i+len(sep)]) == sep {
+			return i
+		}
+	}
+	return -1
 }
 
 func skipIf(input []byte, f func(rune) bool) int {
@@ -530,19 +941,6 @@ func sliceEquals(s1 []byte, s2 []byte) bool {
 	return true
 }
 
-func skipUntilChar(input []byte, char rune) int {
-	i := 0
-	for i < len(input) {
-		r, w := utf8.DecodeRune(input[i:])
-		if r == char {
-			return i
-		} else {
-			i += w
-		}
-	}
-	return i
-}
-
 func skipUntilArray(input []byte, arr []byte) int {
 	i := 0
 	length := len(input)
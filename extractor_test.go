@@ -1,6 +1,7 @@
 package fiptoml
 
 import (
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -368,3 +369,81 @@ func TestIsSectionEnd(t *testing.T) {
 	}
 }
 
+
+func TestExtractValue(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a \"quoted\" \\ string"`, `a "quoted" \ string`},
+		{`"\u00e9t\U0001F600"`, "ét😀"},
+		{`'  C:\Users'`, `  C:\Users`},
+		{"\"\"\"\\\n   Roses \\\n\n  are red\"\"\"", "Roses are red"},
+		{"'''\nraw\\n'''", `raw\n`},
+		{`0`, 0},
+		{`-9223372036854775808`, math.MinInt64},
+		{`+0.5`, 0.5},
+		{"[ 1,\n  2, # two\n  3, ]", []int{1, 2, 3}},
+		{`["a,b", "c]"]`, []string{"a,b", "c]"}},
+		{`[1.5,2.5]`, []float64{1.5, 2.5}},
+		{`inf`, math.Inf(1)},
+		{`[ +inf, -inf ]`, []float64{math.Inf(1), math.Inf(-1)}},
+		{`[]`, []interface{}{}},
+		{"[ # none\n]", []interface{}{}},
+	}
+	for _, c := range cases {
+		val, idx, err := extractValue([]byte(c.input))
+		if err != nil || !reflect.DeepEqual(val, c.expected) || idx != len(c.input) {
+			t.Logf("%q: expected %v, got %v idx: %d err: %v", c.input, c.expected, val, idx, err)
+			t.Fail()
+		}
+	}
+
+	invalid := []string{
+		`"unterminated`,
+		"\"line\nbreak\"",
+		`"bad \q escape"`,
+		`"""unterminated`,
+		`9223372036854775808`,
+		`012`,
+		`infinity`,
+		`nan1`,
+		`-in`,
+		`1.`,
+		`[1, "a"]`,
+		`[[1], [2]]`,
+		`[1 2]`,
+		`2014-12-04T11:09:30`,
+	}
+	for _, input := range invalid {
+		if val, _, err := extractValue([]byte(input)); err == nil {
+			t.Logf("%q: should fail, got %v", input, val)
+			t.Fail()
+		}
+	}
+}
+
+func TestEmptyArray(t *testing.T) {
+	toml, err := ParseString("a = []\n")
+	if err != nil {
+		t.Fatal("an empty array should be read. err:", err)
+	}
+	v, err := toml.GetValue("a")
+	if err != nil || v.Kind() != Array || len(v.Array()) != 0 {
+		t.Log("a should be an empty array, got", v, err)
+		t.Fail()
+	}
+	if toml.String() != "a = []\n" {
+		t.Log("an empty array should be written back, got", toml.String())
+		t.Fail()
+	}
+	if !Equal(toml, toml.Clone()) {
+		t.Log("a copy should equal the document")
+		t.Fail()
+	}
+	err = toml.ApplyEnv(&EnvOptions{Prefix: "APP_", Environ: []string{"APP_A=[ 1, 2 ]"}})
+	if err != nil || len(toml.GetIntArray("a")) != 2 {
+		t.Log("an empty array should take an array of any type, got", err)
+		t.Fail()
+	}
+}
@@ -4,83 +4,39 @@ Package fiptoml provides fast, reliable and easy-to-use parser for TOML doc.
 package fiptoml
 
 import (
-	"io/ioutil"
-	"unicode/utf8"
-	"bufio"
-	"os"
+	"bytes"
+	"io/fs"
 )
 
+//Parse reads a whole document, see Decoder.
 func Parse(input []byte) (doc *Toml, err error) {
-	doc = NewToml()
-	idx, delta := 0, 0
-
-	for idx < len(input) {
-		idx += skipLeft(input[idx:])
-		r, w := utf8.DecodeRune(input[idx:])
-		switch r {
-		case utf8.RuneError:
-			goto Utf8Err
-		case '[':
-			idx += w
-
-			r1, w1 := utf8.DecodeRune(input[idx:])
-			switch r1 {
-			case utf8.RuneError:
-				goto Utf8Err
-			case '[':
-				idx += w1
-				delta, err = extractTableArray(input[idx:], doc)
-			case '\n', '\r', '\f':
-				goto KeyErr
-			default:
-				delta, err = extractTable(input[idx:], doc)
-			}
-		default:
-			delta, err = extractKeyValueSection(input[idx:], doc)
-
-		}
-		idx += delta
-	}
-
-	return
+	return ParseWith(input, nil)
+}
 
-Utf8Err:
-	err = errUtf8
-	return
-KeyErr:
-	err = errInvalidKeyName
-	return
+func ParseWith(input []byte, opts *Options) (doc *Toml, err error) {
+	return NewDecoderWith(bytes.NewReader(input), opts).Decode()
 }
 
+//Load parses the file at path, errors in the document name the file.
 func Load(path string) (doc *Toml, err error) {
-	bytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		return
-	}
-	doc, err = Parse(bytes)
-
-	return
+	return LoadWith(path, nil)
 }
 
-func ParseString(input string) (doc *Toml, err error) {
-	return Parse([]byte(input))
+func LoadWith(path string, opts *Options) (doc *Toml, err error) {
+	return loadFile(osFiles{}, path, opts, nil)
 }
 
-func Write(doc *Toml, path string) (err error) {
-	if doc == nil {
-		return
-	}
-
-	file, err := os.Create(path)
-	defer file.Close()
-
-	if err != nil {
-		return
-	}
+//LoadFS parses the file at path in fsys, as files embedded with go:embed.
+//Paths are slash separated, see fs.FS.
+func LoadFS(fsys fs.FS, path string) (doc *Toml, err error) {
+	return LoadFSWith(fsys, path, nil)
+}
 
-	writer := bufio.NewWriter(file)
-	doc.WriteTo(writer)
-	err = writer.Flush()
-	return
+//LoadFSWith is LoadWith for a file in fsys, files are included from fsys too.
+func LoadFSWith(fsys fs.FS, path string, opts *Options) (doc *Toml, err error) {
+	return loadFile(filesOf(fsys), path, opts, nil)
 }
 
+func ParseString(input string) (doc *Toml, err error) {
+	return Parse([]byte(input))
+}
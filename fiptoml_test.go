@@ -101,3 +101,26 @@ func TestWrite(t *testing.T) {
 	Write(toml,"./config/out.toml")
 }
 
+func TestGetIndexedPath(t *testing.T) {
+	toml, err := ParseString(example)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	if name := toml.GetString("products[-1].name", ""); name != "Nail" {
+		t.Log("products[-1].name should be Nail, but it is:", name)
+		t.Fail()
+	}
+	if port := toml.GetInt("database.ports[2]", 0); port != 8002 {
+		t.Log("database.ports[2] should be 8002, but it is:", port)
+		t.Fail()
+	}
+
+	ports, err := toml.GetPath("database.ports")
+	if err != nil || !reflect.DeepEqual(ports, []int{8001, 8001, 8002}) {
+		t.Log("GetPath database.ports:", ports, "err:", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,186 @@
+package fiptoml
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func errFlagType(val interface{}) error {
+	return errors.New(fmt.Sprint("a flag cannot be set from ", typeName(val)))
+}
+
+func errFlag(name string, err error) error {
+	return errors.New(fmt.Sprint("-", name, ": ", err))
+}
+
+type FlagOptions struct {
+	//Path maps the name of a flag to the path of its value, "" leaving the
+	//flag alone. By default dashes become underscores, so that -db.max-conn
+	//is bound to db.max_conn.
+	Path func(name string) string
+}
+
+func (opts *FlagOptions) path(name string) string {
+	if opts != nil && opts.Path != nil {
+		return opts.Path(name)
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+//SetFlagDefaults sets the flags of fs bound to a value of the document to
+//that value, before fs.Parse, so that the help shows them as defaults. Arrays
+//only become the default shown, the elements joined with commas: repeated
+//flags then replace them rather than add to them, and ApplyFlags leaves them
+//alone unless the flag is set. They may only be bound to flags which are not
+//flag.Getter, or whose Get returns a slice. Flags without a value in the
+//document keep their defaults, and every flag that could not be set is
+//reported.
+func (t *Toml) SetFlagDefaults(fs *flag.FlagSet, opts *FlagOptions) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		path := opts.path(f.Name)
+		if len(path) == 0 {
+			return
+		}
+		val, err := t.GetPath(path)
+		if err != nil {
+			return
+		}
+		if err = setFlag(f, val); err != nil {
+			errs = append(errs, errFlag(f.Name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func setFlag(f *flag.Flag, val interface{}) error {
+	if kindOf(val) != Array {
+		s, ok := flagString(val)
+		if !ok {
+			return errFlagType(val)
+		}
+		if err := f.Value.Set(s); err != nil {
+			return err
+		}
+		f.DefValue = f.Value.String()
+		return nil
+	}
+
+	if getter, ok := f.Value.(flag.Getter); ok && reflect.ValueOf(getter.Get()).Kind() != reflect.Slice {
+		return errFlagType(val)
+	}
+	elems := (Value{val}).Array()
+	strs := make([]string, len(elems))
+	for i, elem := range elems {
+		s, ok := flagString(elem.raw)
+		if !ok {
+			return errFlagType(elem.raw)
+		}
+		strs[i] = s
+	}
+	f.DefValue = strings.Join(strs, ",")
+	return nil
+}
+
+//flagString writes a single value as flags read it
+func flagString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	}
+	return "", false
+}
+
+//ApplyFlags writes the flags of fs set on the command line into the document,
+//after fs.Parse, so that flags take precedence over files. Flags implementing
+//flag.Getter give their value with its type, durations becoming strings, and
+//the others are read as the value they replace, as with ApplyEnv. Missing
+//values are created.
+//
+//Either every flag applies, or the document is left untouched and the error
+//reports every flag that could not. The flag a value came from is recorded as
+//its origin, as "-name", see Origin.
+func (t *Toml) ApplyFlags(fs *flag.FlagSet, opts *FlagOptions) error {
+	if t.frozen {
+		return errFrozen
+	}
+	work := copyTable(t)
+	origins := make(map[string]string, len(t.origins))
+	for path, origin := range t.origins {
+		origins[path] = origin
+	}
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		path := opts.path(f.Name)
+		if len(path) == 0 {
+			return
+		}
+		segs, err := parsePath(path)
+		if err == nil {
+			err = work.setFlag(segs, f)
+		}
+		if err != nil {
+			errs = append(errs, errFlag(f.Name, err))
+			return
+		}
+		origins[formatPath(segs)] = "-" + f.Name
+	})
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	t.dict, t.order, t.origins = work.dict, work.order, origins
+	return nil
+}
+
+func (t *Toml) setFlag(segs []pathSegment, f *flag.Flag) (err error) {
+	old, _ := resolve(t, segs)
+	val, err := flagValue(f, old)
+	if err != nil {
+		return
+	}
+	_, err = rewrite(t, segs, true, func(parent interface{}, seg pathSegment) (interface{}, error) {
+		return setChild(parent, seg, val)
+	})
+	return
+}
+
+//flagValue returns the value of the flag as a value of the type of old, when
+//there is one
+func flagValue(f *flag.Flag, old interface{}) (val interface{}, err error) {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		if old == nil {
+			return f.Value.String(), nil
+		}
+		return envValue(f.Value.String(), old)
+	}
+
+	switch v := getter.Get().(type) {
+	case time.Duration:
+		val = v.String()
+	default:
+		if val, err = normalizeValue(v); err != nil {
+			return
+		}
+	}
+	if i, ok := val.(int); ok && kindOf(old) == Float {
+		val = float64(i)
+	}
+	if old != nil && !sameType(old, val) {
+		return nil, errKindMismatch(old, val)
+	}
+	return
+}
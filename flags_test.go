@@ -0,0 +1,136 @@
+package fiptoml
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(s string) error { *l = append(*l, s); return nil }
+func (l *listFlag) Get() interface{}   { return []string(*l) }
+
+func newFlagSet() (*flag.FlagSet, *listFlag) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.String("title", "none", "")
+	fs.Int("database.connection-max", 10, "")
+	fs.Bool("database.enabled", false, "")
+	fs.Float64("ratio", 1, "")
+	fs.Duration("timeout", time.Second, "")
+	tags := &listFlag{}
+	fs.Var(tags, "tags", "")
+	return fs, tags
+}
+
+func TestSetFlagDefaults(t *testing.T) {
+	toml, _ := ParseString(overlayExample)
+	fs, tags := newFlagSet()
+	if err := toml.SetFlagDefaults(fs, nil); err != nil {
+		t.Fatal("SetFlagDefaults should work. err:", err)
+	}
+	checks := map[string]string{
+		"title":                   "app",
+		"database.connection-max": "5000",
+		"database.enabled":        "true",
+		"ratio":                   "0.5",
+		"timeout":                 "1s",
+		"tags":                    "a,b",
+	}
+	for name, expected := range checks {
+		if f := fs.Lookup(name); f.DefValue != expected || name != "tags" && f.Value.String() != expected {
+			t.Logf("-%s: expected %q, got %q default %q", name, expected, f.Value.String(), f.DefValue)
+			t.Fail()
+		}
+	}
+	if len(*tags) != 0 {
+		t.Log("arrays should only be shown as defaults, got", *tags)
+		t.Fail()
+	}
+
+	toml, _ = ParseString("ratio = \"high\"\n[database]\nenabled = [ \"maybe\" ]")
+	fs, _ = newFlagSet()
+	err := toml.SetFlagDefaults(fs, nil)
+	if err == nil || !strings.Contains(err.Error(), "-ratio: ") || !strings.Contains(err.Error(), "-database.enabled: ") {
+		t.Log("every flag that could not be set should be reported, got", err)
+		t.Fail()
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	toml, _ := ParseString(overlayExample)
+	fs, _ := newFlagSet()
+	toml.SetFlagDefaults(fs, nil)
+	err := fs.Parse([]string{"-database.connection-max", "100", "-ratio", "2", "-timeout", "1m", "-tags", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = toml.ApplyFlags(fs, nil); err != nil {
+		t.Fatal("ApplyFlags should work. err:", err)
+	}
+	if toml.GetInt("database.connection_max", 0) != 100 || toml.GetFloat("ratio", 0) != 2 ||
+		toml.GetString("timeout", "") != "1m0s" || toml.GetString("title", "") != "app" {
+		t.Log("flags set should override values")
+		t.Fail()
+	}
+	if tags := toml.GetStringArray("tags"); len(tags) != 1 || tags[0] != "x" {
+		t.Log("repeated flags should replace the file's values, got", tags)
+		t.Fail()
+	}
+	if toml.Origin("ratio") != "-ratio" || toml.Origin("title") != "" {
+		t.Log("the flag should be recorded as origin, got", toml.Origin("ratio"))
+		t.Fail()
+	}
+
+	toml, _ = ParseString(overlayExample)
+	fs, _ = newFlagSet()
+	toml.SetFlagDefaults(fs, nil)
+	fs.Parse(nil)
+	if err = toml.ApplyFlags(fs, nil); err != nil || len(toml.GetStringArray("tags")) != 2 {
+		t.Log("arrays should be kept when their flag is not set, err:", err)
+		t.Fail()
+	}
+
+	toml, _ = ParseString("title = 1\nratio = 0.5")
+	before := toml.Clone()
+	fs, _ = newFlagSet()
+	fs.Parse([]string{"-title", "x", "-ratio", "3"})
+	if err = toml.ApplyFlags(fs, nil); err == nil || err.Error() != "-title: expected integer, got string" {
+		t.Log("a flag of another type should fail, got", err)
+		t.Fail()
+	}
+	if !Equal(toml, before) {
+		t.Log("a failed ApplyFlags should leave the document untouched")
+		t.Fail()
+	}
+
+	toml = NewToml()
+	fs, _ = newFlagSet()
+	fs.Parse([]string{"-title", "x"})
+	opts := &FlagOptions{Path: func(name string) string { return "cli." + name }}
+	if err = toml.ApplyFlags(fs, opts); err != nil || toml.GetString("cli.title", "") != "x" {
+		t.Log("missing values should be created at the mapped path, err:", err)
+		t.Fail()
+	}
+}
+
+func ExampleToml_ApplyFlags() {
+	toml, _ := ParseString(`[server]
+port = 8080
+host = "localhost"`)
+	fs := flag.NewFlagSet("app", flag.ExitOnError)
+	fs.Int("server.port", 80, "port to listen on")
+	fs.String("server.host", "", "host to listen on")
+
+	toml.SetFlagDefaults(fs, nil)
+	fs.Parse([]string{"-server.port", "9000"})
+	toml.ApplyFlags(fs, nil)
+	fmt.Println(toml.GetString("server.host", ""), toml.GetInt("server.port", 0))
+	// Output:
+	// localhost 9000
+}
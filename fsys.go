@@ -0,0 +1,106 @@
+package fiptoml
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//files is where documents, and the files they include, are read from
+type files interface {
+	open(name string) (fs.File, error)
+	stat(name string) (fs.FileInfo, error)
+	glob(pattern string) ([]string, error)
+	//hasMeta tells whether pattern has characters special to glob
+	hasMeta(pattern string) bool
+	//join resolves name relative to dir, unless it is absolute
+	join(dir, name string) string
+	dir(name string) string
+	//same tells whether two names are the same file
+	same(a, b string) bool
+}
+
+//filesOf reads from fsys, or from the operating system when nil
+func filesOf(fsys fs.FS) files {
+	if fsys == nil {
+		return osFiles{}
+	}
+	return fsFiles{fsys}
+}
+
+type osFiles struct{}
+
+func (osFiles) open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFiles) stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFiles) glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+//backslashes escape characters in patterns, unless they separate names
+func (osFiles) hasMeta(pattern string) bool {
+	if os.PathSeparator == '\\' {
+		return strings.ContainsAny(pattern, "*?[")
+	}
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func (osFiles) join(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+func (osFiles) dir(name string) string {
+	return filepath.Dir(name)
+}
+
+func (osFiles) same(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+//names are slash separated, a leading slash stands for the root of the FS
+type fsFiles struct {
+	fsys fs.FS
+}
+
+func (f fsFiles) open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f fsFiles) stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f fsFiles) glob(pattern string) ([]string, error) {
+	return fs.Glob(f.fsys, pattern)
+}
+
+func (fsFiles) hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func (fsFiles) join(dir, name string) string {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(name[1:])
+	}
+	return path.Join(dir, name)
+}
+
+func (fsFiles) dir(name string) string {
+	return path.Dir(name)
+}
+
+func (fsFiles) same(a, b string) bool {
+	return path.Clean(a) == path.Clean(b)
+}
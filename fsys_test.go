@@ -0,0 +1,103 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/app.toml":            {Data: []byte("include = [ \"defaults.toml\", \"conf.d/*.toml\", \"/shared.toml\" ]\ntitle = \"app\"\n")},
+		"config/defaults.toml":       {Data: []byte("title = \"default\"\nport = 80\nhost = \"localhost\"\n")},
+		"config/conf.d/10-db.toml":   {Data: []byte("[database]\nhost = \"db\"\n")},
+		"config/conf.d/20-port.toml": {Data: []byte("port = 8080\n")},
+		"shared.toml":                {Data: []byte("region = \"eu\"\n")},
+	}
+	toml, err := LoadFS(fsys, "config/app.toml")
+	if err != nil {
+		t.Fatal("LoadFS should work. err:", err)
+	}
+	if toml.GetString("title", "") != "app" || !toml.Has("include") {
+		t.Log("without Includes the include key should be kept")
+		t.Fail()
+	}
+
+	toml, err = LoadFSWith(fsys, "config/app.toml", &Options{Includes: true})
+	if err != nil {
+		t.Fatal("LoadFSWith should work. err:", err)
+	}
+	if toml.GetString("title", "") != "app" || toml.GetInt("port", 0) != 8080 ||
+		toml.GetString("database.host", "") != "db" || toml.GetString("region", "") != "eu" {
+		t.Log("the files should be included from the FS")
+		t.Fail()
+	}
+	if toml.Origin("port") != "config/conf.d/20-port.toml" {
+		t.Log("the origin should be the path in the FS, got", toml.Origin("port"))
+		t.Fail()
+	}
+
+	_, err = LoadFS(fsys, "missing.toml")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Log("a missing file should fail with fs.ErrNotExist, got", err)
+		t.Fail()
+	}
+
+	fsys["config/defaults.toml"] = &fstest.MapFile{Data: []byte("include = \"app.toml\"\n")}
+	_, err = LoadFSWith(fsys, "config/app.toml", &Options{Includes: true})
+	if err == nil || !strings.HasPrefix(err.Error(), "include cycle: config/app.toml -> config/defaults.toml -> config/app.toml") {
+		t.Log("cycles should be reported in the FS too, got", err)
+		t.Fail()
+	}
+}
+
+func TestHasMeta(t *testing.T) {
+	cases := []struct {
+		pattern string
+		os, fs  bool
+	}{
+		{"conf.d/app.toml", false, false},
+		{"conf.d/*.toml", true, true},
+		{"conf.d/app?.toml", true, true},
+		{"conf.d/[ab].toml", true, true},
+		{`conf.d\app.toml`, os.PathSeparator != '\\', true},
+	}
+	for _, c := range cases {
+		if (osFiles{}).hasMeta(c.pattern) != c.os || (fsFiles{}).hasMeta(c.pattern) != c.fs {
+			t.Log("wrong glob characters in", c.pattern)
+			t.Fail()
+		}
+	}
+}
+
+func TestWatcherFS(t *testing.T) {
+	now := time.Now()
+	fsys := fstest.MapFS{"app.toml": {Data: []byte("port = 80\n"), ModTime: now}}
+	w, err := NewWatcher(&WatchOptions{FS: fsys}, "app.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["app.toml"] = &fstest.MapFile{Data: []byte("port = 8080\n"), ModTime: now.Add(time.Second)}
+	if err = w.Reload(); err != nil || w.Current().GetInt("port", 0) != 8080 {
+		t.Log("the watcher should reload from the FS, err:", err)
+		t.Fail()
+	}
+}
+
+func ExampleLoadFS() {
+	//an embed.FS works the same
+	fsys := fstest.MapFS{"defaults.toml": {Data: []byte("port = 8080")}}
+	toml, err := LoadFS(fsys, "defaults.toml")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(toml.GetInt("port", 0))
+	// Output:
+	// 8080
+}
@@ -0,0 +1,107 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+var errIncludeType = errors.New("include should be a string or an array of strings")
+
+func errIncludeCycle(stack []string, path string) error {
+	return errors.New(fmt.Sprint("include cycle: ", strings.Join(append(stack, path), " -> ")))
+}
+
+//loadFile loads the file at path, which was included by the files of stack
+func loadFile(fsys files, path string, opts *Options, stack []string) (doc *Toml, err error) {
+	file, err := fsys.open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	d := NewDecoderWith(file, opts)
+	d.s.file = path
+	d.files = fsys
+	d.stack = stack
+	return d.Decode()
+}
+
+//include merges the files listed by the include key at the top of the
+//document, in order, the files a pattern matches in sorted order, then the
+//document over them. Relative paths are relative to the directory of the
+//document, or to the working directory, or the root of the FS, when it was not
+//loaded from a file.
+func (d *Decoder) include(doc *Toml) (*Toml, error) {
+	var patterns []string
+	switch v := doc.dict["include"].(type) {
+	case nil:
+		return doc, nil
+	case string:
+		patterns = []string{v}
+	case []string:
+		patterns = v
+	default:
+		return nil, d.s.wrap(errIncludeType)
+	}
+	delete(doc.dict, "include")
+
+	//variables and profiles apply once the files are merged, the variables of
+	//the paths excepted
+	fragOpts := d.opts
+	fragOpts.ExpandEnv, fragOpts.ResolveRefs, fragOpts.Profile = false, false, ""
+
+	var included *Toml
+	fsys := d.files
+	dir, stack := ".", d.stack
+	if len(d.s.file) > 0 {
+		dir = fsys.dir(d.s.file)
+		stack = append(stack[:len(stack):len(stack)], d.s.file)
+	}
+	for _, pattern := range patterns {
+		if d.opts.ExpandEnv {
+			var err error
+			if pattern, err = expand(pattern, d.opts.lookupEnv()); err != nil {
+				return nil, d.s.wrap(errExpand(appendKey(nil, "include"), err))
+			}
+		}
+		pattern = fsys.join(dir, pattern)
+		paths, err := fsys.glob(pattern)
+		if err != nil {
+			return nil, d.s.wrap(err)
+		}
+		//a missing file is an error, a pattern matching nothing is not
+		if len(paths) == 0 && !fsys.hasMeta(pattern) {
+			paths = []string{pattern}
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			if inStack(fsys, stack, path) {
+				return nil, errIncludeCycle(stack, path)
+			}
+			fragment, err := loadFile(fsys, path, &fragOpts, stack)
+			if _, ok := err.(*fs.PathError); ok {
+				return nil, d.s.wrap(err)
+			} else if err != nil {
+				return nil, err
+			}
+			included = Merge(included, fragment, nil)
+		}
+	}
+
+	result := Merge(included, doc, nil)
+	result.name = doc.name
+	return result, nil
+}
+
+func inStack(fsys files, stack []string, path string) bool {
+	for _, p := range stack {
+		if fsys.same(p, path) {
+			return true
+		}
+	}
+	return false
+}
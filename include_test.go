@@ -0,0 +1,110 @@
+package fiptoml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.toml":            "include = [ \"defaults.toml\", \"conf.d/*.toml\" ]\ntitle = \"app\"\n",
+		"defaults.toml":       "title = \"defaults\"\nlevel = \"info\"\n\n[database]\nport = 5432\nhost = \"localhost\"\n",
+		"conf.d/20-port.toml": "[database]\nport = 5434\n",
+		"conf.d/10-port.toml": "[database]\nport = 5433\nuser = \"${USER}\"\n",
+		"conf.d/nested.toml":  "include = \"../extra/more.toml\"\n",
+		"extra/more.toml":     "level = \"debug\"\n",
+		"conf.d/skipped.conf": "level = \"skipped\"\n",
+	})
+
+	app := filepath.Join(dir, "app.toml")
+	opts := &Options{Includes: true, ExpandEnv: true, LookupEnv: lookupMap(map[string]string{"USER": "admin"})}
+	toml, err := LoadWith(app, opts)
+	if err != nil {
+		t.Fatal("LoadWith should work. err:", err)
+	}
+	if toml.Has("include") || toml.GetString("title", "") != "app" ||
+		toml.GetInt("database.port", 0) != 5434 || toml.GetString("database.host", "") != "localhost" ||
+		toml.GetString("database.user", "") != "admin" || toml.GetString("level", "") != "debug" {
+		t.Log("included files should be merged in order, the including file last")
+		t.Fail()
+	}
+
+	origins := map[string]string{
+		"title":         app,
+		"level":         filepath.Join(dir, "extra/more.toml"),
+		"database.port": filepath.Join(dir, "conf.d/20-port.toml"),
+		"database.host": filepath.Join(dir, "defaults.toml"),
+	}
+	for path, origin := range origins {
+		if toml.Origin(path) != origin {
+			t.Log(path, "should come from", origin, "got", toml.Origin(path))
+			t.Fail()
+		}
+	}
+
+	toml, _ = Load(app)
+	if !toml.Has("include") || toml.Has("database") {
+		t.Log("includes should be opt-in")
+		t.Fail()
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.toml":       "include = \"b.toml\"\n",
+		"b.toml":       "include = [ \"c.toml\" ]\n",
+		"c.toml":       "include = \"a.toml\"\n",
+		"missing.toml": "include = \"nowhere.toml\"\n",
+		"empty.toml":   "include = \"conf.d/*.toml\"\nx = 1\n",
+		"typed.toml":   "include = 1\n",
+		"broken.toml":  "include = \"bad.toml\"\n",
+		"bad.toml":     "x = 1\nx = 2\n",
+	})
+	opts := &Options{Includes: true}
+
+	_, err := LoadWith(filepath.Join(dir, "a.toml"), opts)
+	if err == nil || !strings.HasPrefix(err.Error(), "include cycle: ") || !strings.HasSuffix(err.Error(), "c.toml -> "+filepath.Join(dir, "a.toml")) {
+		t.Log("a cycle should be detected, got", err)
+		t.Fail()
+	}
+
+	_, err = LoadWith(filepath.Join(dir, "missing.toml"), opts)
+	if !errors.Is(err, os.ErrNotExist) || !strings.HasPrefix(err.Error(), filepath.Join(dir, "missing.toml")+": ") {
+		t.Log("a missing file should be reported by the including file, got", err)
+		t.Fail()
+	}
+
+	if toml, err := LoadWith(filepath.Join(dir, "empty.toml"), opts); err != nil || toml.GetInt("x", 0) != 1 {
+		t.Log("a pattern matching nothing should be fine, got", err)
+		t.Fail()
+	}
+
+	if _, err = LoadWith(filepath.Join(dir, "typed.toml"), opts); err == nil {
+		t.Log("include should be a string or an array of strings")
+		t.Fail()
+	}
+
+	_, err = LoadWith(filepath.Join(dir, "broken.toml"), opts)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.File != filepath.Join(dir, "bad.toml") || pe.Line != 2 {
+		t.Log("an error in an included file should name it, got", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,277 @@
+package fiptoml
+
+import (
+	"reflect"
+	"strings"
+)
+
+//MergeStrategy tells Merge how to combine a value of the overlay with the
+//value at the same path in the base.
+type MergeStrategy int
+
+const (
+	//the default of the kind of value, see MergeOptions
+	MergeDefault MergeStrategy = iota
+	//the overlay value replaces the base value
+	MergeReplace
+	//tables are merged key by key
+	MergeDeep
+	//arrays and arrays of tables: the overlay elements follow the base ones
+	MergeAppend
+	//arrays of tables: elements with the same key field, see MergeOptions.Keys,
+	//are merged deeply, the others appended
+	MergeByKey
+)
+
+//MergeOptions configures Merge. A strategy which does not apply to a kind of
+//value falls back to the default of that kind.
+type MergeOptions struct {
+	Tables      MergeStrategy //MergeDeep by default
+	Arrays      MergeStrategy //MergeReplace by default
+	TableArrays MergeStrategy //MergeReplace by default
+
+	//strategies for single paths, taking precedence over the ones above.
+	//Elements of arrays of tables may be written as [*], as in products[*].tags
+	Paths map[string]MergeStrategy
+	//the key field identifying the elements of the arrays of tables merged
+	//MergeByKey, by path
+	Keys map[string]string
+
+	//names of the layers recorded as origins, the names of the files the
+	//documents were loaded from by default
+	BaseName    string
+	OverlayName string
+}
+
+type merger struct {
+	opts        *MergeOptions
+	result      *Toml
+	overlay     *Toml
+	overlayName string
+}
+
+//Merge returns a new document with the values of overlay merged over the
+//values of base, neither being modified. The layer every value came from is
+//recorded, see Origin, so layers can be merged one after the other:
+//
+//	doc := Merge(Merge(defaults, production, nil), override, nil)
+//	doc.Origin("database.port") //"override.toml"
+func Merge(base, overlay *Toml, opts *MergeOptions) *Toml {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	if base == nil {
+		base = NewToml()
+	}
+
+	result := copyTable(base)
+	result.name = layerName(base, opts.BaseName)
+	result.origins = make(map[string]string, len(base.origins))
+	for path, origin := range base.origins {
+		result.origins[path] = origin
+	}
+	if overlay == nil {
+		return result
+	}
+
+	m := &merger{opts, result, overlay, layerName(overlay, opts.OverlayName)}
+	m.mergeTable(result, overlay, nil, nil)
+	return result
+}
+
+func layerName(doc *Toml, name string) string {
+	if len(name) > 0 {
+		return name
+	}
+	return doc.name
+}
+
+//Origin returns the name of the layer the value at path came from when the
+//document is the result of Merge, otherwise the name of the file the document
+//was loaded from. It is empty when unknown.
+func (t *Toml) Origin(path string) string {
+	segs, err := parsePath(path)
+	if err != nil {
+		return t.name
+	}
+	return t.originOf(segs, t.name)
+}
+
+//moveOrigins keeps the origins in line with the document once the value at
+//segs, an absolute path, was removed (delta -1) or inserted in an array
+//(delta 1): the origins of what was removed are forgotten, and those of the
+//elements following an array element move with them.
+func (t *Toml) moveOrigins(segs []pathSegment, delta int) {
+	if len(t.origins) == 0 {
+		return
+	}
+	last := segs[len(segs)-1]
+	origins := make(map[string]string, len(t.origins))
+	for path, origin := range t.origins {
+		p, err := parsePath(path)
+		if err != nil || len(p) < len(segs) || !segsEqual(p[:len(segs)-1], segs[:len(segs)-1]) {
+			origins[path] = origin
+			continue
+		}
+		seg := p[len(segs)-1]
+		switch {
+		case !last.isIndex || !seg.isIndex:
+			if delta > 0 || seg != last {
+				origins[path] = origin
+			}
+		case seg.index == last.index && delta < 0:
+		case seg.index >= last.index:
+			p[len(segs)-1].index += delta
+			origins[formatPath(p)] = origin
+		default:
+			origins[path] = origin
+		}
+	}
+	t.origins = origins
+}
+
+func segsEqual(a, b []pathSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//the origin recorded for the path or the closest of its parents
+func (t *Toml) originOf(segs []pathSegment, dflt string) string {
+	for i := len(segs); i > 0; i-- {
+		if origin, ok := t.origins[formatPath(segs[:i])]; ok {
+			return origin
+		}
+	}
+	return dflt
+}
+
+//dst and src are the paths of the tables in the result and in the overlay,
+//they differ inside arrays of tables
+func (m *merger) mergeTable(table, overlay *Toml, dst, src []pathSegment) {
+	for _, key := range overlay.orderedKeys() {
+		d, s := appendKey(dst, key), appendKey(src, key)
+		if base, ok := table.dict[key]; ok {
+			table.set(key, m.mergeValue(base, overlay.dict[key], d, s))
+		} else {
+			table.set(key, m.take(overlay.dict[key], d, s))
+		}
+	}
+}
+
+func (m *merger) mergeValue(base, overlay interface{}, dst, src []pathSegment) interface{} {
+	kind := kindOf(overlay)
+	if kindOf(base) != kind {
+		return m.take(overlay, dst, src)
+	}
+
+	switch kind {
+	case Table:
+		if m.strategy(dst, kind) == MergeReplace {
+			break
+		}
+		m.mergeTable(base.(*Toml), overlay.(*Toml), dst, src)
+		return base
+	case Array:
+		if m.strategy(dst, kind) != MergeAppend {
+			break
+		}
+		array := reflect.ValueOf(copyValue(base))
+		elems := reflect.ValueOf(overlay)
+		if array.Type() != elems.Type() {
+			break
+		}
+		for i := 0; i < elems.Len(); i++ {
+			m.record(appendIndex(dst, array.Len()), appendIndex(src, i))
+			array = reflect.Append(array, elems.Index(i))
+		}
+		return array.Interface()
+	case TableArray:
+		strategy := m.strategy(dst, kind)
+		if strategy != MergeAppend && strategy != MergeByKey {
+			break
+		}
+		key := m.key(dst)
+		array := base.([]*Toml)
+	Elems:
+		for i, elem := range overlay.([]*Toml) {
+			s := appendIndex(src, i)
+			if strategy == MergeByKey && len(key) > 0 {
+				if id, ok := elem.dict[key]; ok {
+					for j, b := range array {
+						if c, ok := compareValues(b.dict[key], id); ok && c == 0 {
+							m.mergeTable(b, elem, appendIndex(dst, j), s)
+							continue Elems
+						}
+					}
+				}
+			}
+			array = append(array, m.take(elem, appendIndex(dst, len(array)), s).(*Toml))
+		}
+		return array
+	}
+	return m.take(overlay, dst, src)
+}
+
+func (m *merger) strategy(path []pathSegment, kind Kind) (strategy MergeStrategy) {
+	var ok bool
+	if strategy, ok = m.opts.Paths[formatPath(path)]; !ok {
+		strategy = m.opts.Paths[formatPattern(path)]
+	}
+	if strategy == MergeDefault {
+		switch kind {
+		case Table:
+			strategy = m.opts.Tables
+		case Array:
+			strategy = m.opts.Arrays
+		case TableArray:
+			strategy = m.opts.TableArrays
+		}
+	}
+	return
+}
+
+func (m *merger) key(path []pathSegment) string {
+	if key, ok := m.opts.Keys[formatPath(path)]; ok {
+		return key
+	}
+	return m.opts.Keys[formatPattern(path)]
+}
+
+//take copies a value of the overlay into the result
+func (m *merger) take(v interface{}, dst, src []pathSegment) interface{} {
+	m.record(dst, src)
+	return copyValue(v)
+}
+
+//record the origin of the value at src in the overlay for the path dst of the
+//result, replacing what was recorded below dst
+func (m *merger) record(dst, src []pathSegment) {
+	d, s := formatPath(dst), formatPath(src)
+	for path := range m.result.origins {
+		if isPathPrefix(d, path) {
+			delete(m.result.origins, path)
+		}
+	}
+	m.result.origins[d] = m.overlay.originOf(src, m.overlayName)
+	for path, origin := range m.overlay.origins {
+		if isPathPrefix(s, path) {
+			m.result.origins[d+path[len(s):]] = origin
+		}
+	}
+}
+
+//path is prefix or lies below it
+func isPathPrefix(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return len(rest) == 0 || rest[0] == '.' || rest[0] == '['
+}
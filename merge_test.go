@@ -0,0 +1,158 @@
+package fiptoml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	defaults, _ := ParseString(`title = "defaults"
+tags = [ "a", "b" ]
+
+[database]
+server = "localhost"
+ports = [ 8001 ]
+enabled = false
+
+[[plugins]]
+name = "auth"
+level = 1
+
+[[plugins]]
+name = "cache"
+level = 1
+`)
+	defaults.name = "defaults.toml"
+	production, _ := ParseString(`title = "production"
+tags = [ "c" ]
+
+[database]
+server = "db.example.com"
+ports = [ 9001, 9002 ]
+
+[[plugins]]
+name = "cache"
+level = 2
+
+[[plugins]]
+name = "metrics"
+level = 1
+`)
+	production.name = "production.toml"
+
+	doc := Merge(defaults, production, &MergeOptions{
+		Arrays:      MergeAppend,
+		TableArrays: MergeByKey,
+		Paths:       map[string]MergeStrategy{"database.ports": MergeReplace},
+		Keys:        map[string]string{"plugins": "name"},
+	})
+
+	if doc.GetString("title", "") != "production" ||
+		doc.GetString("database.server", "") != "db.example.com" ||
+		doc.GetBool("database.enabled", true) {
+		t.Log("Merge should override values and keep the others:", doc.dict)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(doc.GetStringArray("tags"), []string{"a", "b", "c"}) ||
+		!reflect.DeepEqual(doc.GetIntArray("database.ports"), []int{9001, 9002}) {
+		t.Log("Merge arrays:", doc.GetStringArray("tags"), doc.GetIntArray("database.ports"))
+		t.Fail()
+	}
+	plugins, _ := doc.GetTableArray("plugins")
+	if len(plugins) != 3 || plugins[1].GetInt("level", 0) != 2 || plugins[2].GetString("name", "") != "metrics" {
+		t.Log("Merge should merge plugins by name:", plugins)
+		t.Fail()
+	}
+
+	origins := map[string]string{
+		"title":             "production.toml",
+		"database.enabled":  "defaults.toml",
+		"database.ports[1]": "production.toml",
+		"tags[1]":           "defaults.toml",
+		"tags[2]":           "production.toml",
+		"plugins[0].level":  "defaults.toml",
+		"plugins[1].level":  "production.toml",
+		"plugins[1]":        "defaults.toml",
+		"plugins[2].name":   "production.toml",
+	}
+	for path, origin := range origins {
+		if doc.Origin(path) != origin {
+			t.Log("Origin of", path, "is", doc.Origin(path), "want", origin)
+			t.Fail()
+		}
+	}
+
+	if defaults.GetString("title", "") != "defaults" || len(defaults.GetStringArray("tags")) != 2 {
+		t.Log("Merge should not modify the base")
+		t.Fail()
+	}
+	doc.Set("database.ports[0]", 1)
+	if production.GetInt("database.ports[0]", 0) != 9001 {
+		t.Log("Merge should not share arrays with the overlay")
+		t.Fail()
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	defaults, _ := ParseString("a = 1\nb = 1\nc = 1\n\n[t]\nx = 1\ny = 1\n")
+	production, _ := ParseString("b = 2\n\n[t]\ny = 2\n")
+	override, _ := ParseString("c = 3\n\n[t]\nx = 3\n")
+
+	doc := Merge(Merge(defaults, production, &MergeOptions{BaseName: "defaults", OverlayName: "production"}),
+		override, &MergeOptions{OverlayName: "override", Tables: MergeReplace})
+
+	if doc.GetInt("a", 0) != 1 || doc.GetInt("b", 0) != 2 || doc.GetInt("c", 0) != 3 ||
+		doc.GetInt("t.x", 0) != 3 || doc.Has("t.y") {
+		t.Log("Merge of three layers:", doc.dict)
+		t.Fail()
+	}
+	origins := map[string]string{"a": "defaults", "b": "production", "c": "override", "t.x": "override"}
+	for path, origin := range origins {
+		if doc.Origin(path) != origin {
+			t.Log("Origin of", path, "is", doc.Origin(path), "want", origin)
+			t.Fail()
+		}
+	}
+}
+
+func TestOriginAfterMutation(t *testing.T) {
+	base, _ := ParseString("[[servers]]\nip = \"a\"\n\n[[servers]]\nip = \"b\"\n")
+	overlay, _ := ParseString("[[servers]]\nip = \"c\"\n")
+	doc := Merge(base, overlay, &MergeOptions{TableArrays: MergeAppend, BaseName: "base", OverlayName: "overlay"})
+
+	if err := doc.Delete("servers[0]"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Origin("servers[0].ip") != "base" || doc.Origin("servers[1].ip") != "overlay" {
+		t.Log("origins should follow the elements after a Delete, got",
+			doc.Origin("servers[0].ip"), doc.Origin("servers[1].ip"))
+		t.Fail()
+	}
+
+	err := doc.ApplyPatch([]PatchOp{{Op: "add", Path: "servers[0]", Value: map[string]interface{}{"ip": "d"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Origin("servers[0].ip") != "base" || doc.Origin("servers[1].ip") != "base" ||
+		doc.Origin("servers[2].ip") != "overlay" {
+		t.Log("origins should follow the elements after an insertion, got", doc.origins)
+		t.Fail()
+	}
+
+	doc.Delete("servers[-1]")
+	if _, ok := doc.origins["servers[2].ip"]; ok {
+		t.Log("the origins of removed values should be forgotten, got", doc.origins)
+		t.Fail()
+	}
+}
+
+func TestMergeByKeyNaN(t *testing.T) {
+	base, _ := ParseString("[[points]]\nx = nan\nname = \"a\"\n")
+	overlay, _ := ParseString("[[points]]\nx = nan\nname = \"b\"\n")
+	doc := Merge(base, overlay, &MergeOptions{TableArrays: MergeByKey, Keys: map[string]string{"points": "x"}})
+	points, _ := doc.GetTableArray("points")
+	if len(points) != 2 {
+		t.Log("NaN keys should match nothing, got", points)
+		t.Fail()
+	}
+}
@@ -0,0 +1,339 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+func errUnsupportedType(v interface{}) error {
+	return errors.New(fmt.Sprint("unsupported value type: ", reflect.TypeOf(v)))
+}
+
+//Set stores v at path. Missing tables on the way are created, and an index
+//one past the end of an array, or of an array of tables, appends to it.
+//
+//v must be representable in TOML: strings, bools, integers, floats,
+//time.Time, homogeneous slices of those, *Toml, []*Toml, or
+//map[string]interface{} which is stored as a table. Other integer and float
+//types are converted to int and float64. Slices and tables are copied.
+func (t *Toml) Set(path string, v interface{}) (err error) {
+	if t.frozen {
+		return errFrozen
+	}
+	segs, err := parsePath(path)
+	if err != nil {
+		return
+	}
+	val, err := normalizeValue(v)
+	if err != nil {
+		return
+	}
+
+	_, err = rewrite(t, segs, true, func(parent interface{}, seg pathSegment) (interface{}, error) {
+		return setChild(parent, seg, val)
+	})
+	return
+}
+
+//Delete removes the key or the array element at path. The origins of the
+//values removed are forgotten, and those of the following elements of an
+//array follow them, see Origin.
+func (t *Toml) Delete(path string) (err error) {
+	if t.frozen {
+		return errFrozen
+	}
+	segs, err := parsePath(path)
+	if err != nil {
+		return
+	}
+	segs = t.absPath(segs)
+	if _, err = rewrite(t, segs, false, deleteChild); err == nil {
+		t.moveOrigins(segs, -1)
+	}
+	return
+}
+
+//AppendTable appends a new table to the array of tables at path, creating
+//the array when missing, and returns the new table. It returns nil when path
+//holds something else or the document is frozen.
+func (t *Toml) AppendTable(path string) *Toml {
+	segs, err := parsePath(path)
+	if err != nil || segs[len(segs)-1].isIndex || t.frozen {
+		return nil
+	}
+
+	table := NewToml()
+	_, err = rewrite(t, segs, true, func(parent interface{}, seg pathSegment) (interface{}, error) {
+		p, err := asTable(parent)
+		if err != nil {
+			return nil, err
+		}
+		switch array := p.dict[seg.key].(type) {
+		case nil:
+			p.set(seg.key, []*Toml{table})
+		case []*Toml:
+			p.set(seg.key, append(array, table))
+		default:
+			return nil, errTypeMismatch
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil
+	}
+	return table
+}
+
+//rewrite descends along segs and lets f replace the container holding the last
+//segment. Containers are stored back on the way up, so that arrays grown or
+//shrunk by f are seen by their parents. When create is set, missing tables and
+//arrays of tables are created, but only linked into the document once f
+//succeeds.
+func rewrite(container interface{}, segs []pathSegment, create bool,
+	f func(parent interface{}, seg pathSegment) (interface{}, error)) (interface{}, error) {
+	if container == nil && !create {
+		return nil, errValueNotFound
+	}
+	//a frozen table fails even when the document holding it is not
+	if table, ok := container.(*Toml); ok && table.frozen {
+		return nil, errFrozen
+	}
+	if len(segs) == 1 {
+		return f(container, segs[0])
+	}
+
+	seg := segs[0]
+	if !seg.isIndex {
+		table, err := asTable(container)
+		if err != nil {
+			return nil, err
+		}
+		cur, ok := table.dict[seg.key]
+		if !ok && !create {
+			return nil, errValueNotFound
+		}
+		cur, err = rewrite(cur, segs[1:], create, f)
+		if err != nil {
+			return nil, err
+		}
+		table.set(seg.key, cur)
+		return table, nil
+	}
+
+	var array []*Toml
+	switch v := container.(type) {
+	case nil:
+	case []*Toml:
+		array = v
+	case []interface{}:
+		//an empty array without a type becomes an array of tables
+		if len(v) > 0 {
+			return nil, errTypeMismatch
+		}
+	default:
+		//elements of other arrays have no children
+		return nil, errTypeMismatch
+	}
+	if seg.index == len(array) && create {
+		table, err := rewriteTable(nil, segs[1:], create, f)
+		if err != nil {
+			return nil, err
+		}
+		return append(array, table), nil
+	}
+	i, ok := arrayIndex(seg.index, len(array))
+	if !ok {
+		return nil, errValueNotFound
+	}
+	table, err := rewriteTable(array[i], segs[1:], create, f)
+	if err != nil {
+		return nil, err
+	}
+	array[i] = table
+	return array, nil
+}
+
+//elements of an array of tables must stay tables
+func rewriteTable(table *Toml, segs []pathSegment, create bool,
+	f func(parent interface{}, seg pathSegment) (interface{}, error)) (*Toml, error) {
+	var container interface{}
+	if table != nil {
+		container = table
+	}
+	v, err := rewrite(container, segs, create, f)
+	if err != nil {
+		return nil, err
+	}
+	if table, ok := v.(*Toml); ok {
+		return table, nil
+	}
+	return nil, errTypeMismatch
+}
+
+//a missing container becomes a new table
+func asTable(container interface{}) (*Toml, error) {
+	switch v := container.(type) {
+	case nil:
+		return NewToml(), nil
+	case *Toml:
+		return v, nil
+	default:
+		return nil, errTypeMismatch
+	}
+}
+
+func setChild(parent interface{}, seg pathSegment, val interface{}) (interface{}, error) {
+	if !seg.isIndex {
+		table, err := asTable(parent)
+		if err != nil {
+			return nil, err
+		}
+		table.set(seg.key, val)
+		return table, nil
+	}
+
+	elem := reflect.ValueOf(val)
+	parent = typedArray(parent, elem)
+	array := reflect.ValueOf(parent)
+	if array.Kind() != reflect.Slice || array.Type().Elem() != elem.Type() {
+		return nil, errTypeMismatch
+	}
+	i, ok := arrayIndex(seg.index, array.Len())
+	if !ok && seg.index != array.Len() {
+		return nil, errValueNotFound
+	}
+	//a new array, the old one may be shared
+	dup := reflect.MakeSlice(array.Type(), array.Len(), array.Len()+1)
+	reflect.Copy(dup, array)
+	if !ok {
+		return reflect.Append(dup, elem).Interface(), nil
+	}
+	dup.Index(i).Set(elem)
+	return dup.Interface(), nil
+}
+
+//typedArray turns a missing array, or an empty one without a type of elements,
+//into an empty array of the type of elem
+func typedArray(array interface{}, elem reflect.Value) interface{} {
+	if untyped, ok := array.([]interface{}); array == nil || ok && len(untyped) == 0 {
+		return reflect.MakeSlice(reflect.SliceOf(elem.Type()), 0, 1).Interface()
+	}
+	return array
+}
+
+func deleteChild(parent interface{}, seg pathSegment) (interface{}, error) {
+	if !seg.isIndex {
+		table, ok := parent.(*Toml)
+		if !ok {
+			return nil, errValueNotFound
+		}
+		if _, ok := table.dict[seg.key]; !ok {
+			return nil, errValueNotFound
+		}
+		delete(table.dict, seg.key)
+		return table, nil
+	}
+
+	array := reflect.ValueOf(parent)
+	if array.Kind() != reflect.Slice {
+		return nil, errValueNotFound
+	}
+	i, ok := arrayIndex(seg.index, array.Len())
+	if !ok {
+		return nil, errValueNotFound
+	}
+	//a new array, the old one may be shared
+	rest := reflect.MakeSlice(array.Type(), 0, array.Len()-1)
+	rest = reflect.AppendSlice(rest, array.Slice(0, i))
+	rest = reflect.AppendSlice(rest, array.Slice(i+1, array.Len()))
+	return rest.Interface(), nil
+}
+
+//normalizeValue converts v to one of the types a document holds, or fails if
+//TOML cannot represent it.
+func normalizeValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	//slices and tables are copied, so that the document cannot change
+	//through them, frozen or not
+	case string, bool, int, float64, time.Time,
+		[]string, []bool, []int, []float64, []time.Time, []*Toml:
+		return copyValue(v), nil
+	case *Toml:
+		if x == nil {
+			return nil, errUnsupportedType(v)
+		}
+		return copyTable(x), nil
+	case map[string]interface{}:
+		//maps have no order, their keys are added sorted
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		table := NewToml()
+		for _, key := range keys {
+			val, err := normalizeValue(x[key])
+			if err != nil {
+				return nil, err
+			}
+			table.set(key, val)
+		}
+		return table, nil
+	case nil:
+		return nil, errUnsupportedType(v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < math.MinInt || rv.Int() > math.MaxInt {
+			return nil, errUnsupportedType(v)
+		}
+		return int(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt {
+			return nil, errUnsupportedType(v)
+		}
+		return int(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Slice, reflect.Array:
+		return normalizeArray(rv)
+	}
+	return nil, errUnsupportedType(v)
+}
+
+//elements must all normalize to the same type, arrays of arrays are not supported
+func normalizeArray(rv reflect.Value) (interface{}, error) {
+	//the elements give the type of the array, an empty one has none
+	if rv.Len() == 0 {
+		return []interface{}{}, nil
+	}
+
+	var array reflect.Value
+	for i := 0; i < rv.Len(); i++ {
+		val, err := normalizeValue(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		elem := reflect.ValueOf(val)
+		if i == 0 {
+			if elem.Kind() == reflect.Slice {
+				return nil, errUnsupportedType(rv.Interface())
+			}
+			array = reflect.MakeSlice(reflect.SliceOf(elem.Type()), 0, rv.Len())
+		} else if elem.Type() != array.Type().Elem() {
+			return nil, errArray
+		}
+		array = reflect.Append(array, elem)
+	}
+	return array.Interface(), nil
+}
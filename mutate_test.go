@@ -0,0 +1,195 @@
+package fiptoml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	toml := NewToml()
+	if err := toml.Set("database.port", 5432); err != nil || toml.GetInt("database.port", 0) != 5432 {
+		t.Log("Set should create the database table. err:", err)
+		t.Fail()
+	}
+	if _, ok := toml.dict["database.port"]; ok {
+		t.Log("Set should not create a key with a dot in it")
+		t.Fail()
+	}
+
+	if err := toml.Set("products[0].name", "Hammer"); err != nil {
+		t.Log("Set should create the array of tables. err:", err)
+		t.Fail()
+	}
+	if err := toml.Set("products[1].name", "Nail"); err != nil {
+		t.Log("Set should append to the array of tables. err:", err)
+		t.Fail()
+	}
+	if err := toml.Set("products[-1].sku", uint16(42)); err != nil {
+		t.Log("Set should convert integers. err:", err)
+		t.Fail()
+	}
+	if toml.GetString("products[0].name", "") != "Hammer" || toml.GetInt("products[1].sku", 0) != 42 {
+		t.Log("Set products:", toml.dict["products"])
+		t.Fail()
+	}
+
+	if err := toml.Set("ports", []interface{}{int64(8001), 8002}); err != nil ||
+		!reflect.DeepEqual(toml.GetIntArray("ports"), []int{8001, 8002}) {
+		t.Log("Set should store a typed array. err:", err)
+		t.Fail()
+	}
+	if err := toml.Set("ports[2]", 8003); err != nil || toml.GetInt("ports[-1]", 0) != 8003 {
+		t.Log("Set should append to the array. err:", err)
+		t.Fail()
+	}
+	if err := toml.Set("ports[0]", 8000); err != nil || toml.GetInt("ports[0]", 0) != 8000 {
+		t.Log("Set should replace the element. err:", err)
+		t.Fail()
+	}
+
+	if err := toml.Set("owner", map[string]interface{}{"name": "Tom", "age": int32(3)}); err != nil ||
+		toml.GetString("owner.name", "") != "Tom" || toml.GetInt("owner.age", 0) != 3 {
+		t.Log("Set should store a map as a table. err:", err)
+		t.Fail()
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	toml := NewToml()
+	toml.Set("title", "TOML")
+	toml.Set("ports", []int{1, 2})
+	toml.Set("products[0].name", "Hammer")
+
+	cases := []struct {
+		path string
+		v    interface{}
+	}{
+		{"nil", nil},
+		{"map", map[int]string{1: "a"}},
+		{"struct", struct{}{}},
+		{"mixed", []interface{}{1, "a"}},
+		{"nested", [][]int{{1}}},
+		{"title.name", "x"},
+		{"ports[0]", "x"},
+		{"ports[5]", 1},
+		{"ports[0].x", 1},
+		{"products[0]", 1},
+		{"products[3].name", "x"},
+		{"a.[", 1},
+	}
+	for _, c := range cases {
+		if err := toml.Set(c.path, c.v); err == nil {
+			t.Log("Set should fail:", c.path, c.v)
+			t.Fail()
+		}
+		if err := toml.SetValue(c.path, c.v); err == nil {
+			t.Log("SetValue should report the errors of Set:", c.path, c.v)
+			t.Fail()
+		}
+	}
+	if toml.GetString("title", "") != "TOML" || len(toml.dict) != 3 || len(toml.GetIntArray("ports")) != 2 {
+		t.Log("failed Set should leave the document untouched:", toml.dict)
+		t.Fail()
+	}
+}
+
+func TestSetArrayElement(t *testing.T) {
+	toml := NewToml()
+	toml.Set("ports", []int{1, 2, 3})
+	ports := toml.GetIntArray("ports")
+	clone := toml.Clone()
+
+	if err := toml.Set("ports[0]", 10); err != nil || toml.GetIntArray("ports")[0] != 10 {
+		t.Log("Set should replace the element, err:", err)
+		t.Fail()
+	}
+	toml.Set("ports[3]", 4)
+	if ports[0] != 1 || clone.GetIntArray("ports")[0] != 1 || len(toml.GetIntArray("ports")) != 4 {
+		t.Log("Set should not change the arrays handed out before, got", ports)
+		t.Fail()
+	}
+}
+
+func TestSetEmptyArray(t *testing.T) {
+	toml, _ := ParseString("e = []\nt = []\n")
+	e, err := toml.GetPath("e")
+	if err != nil {
+		t.Fatal("GetPath should work. err:", err)
+	}
+	if err = toml.Set("g", e); err != nil || toml.Kind("g") != Array {
+		t.Log("a value read should be set again, err:", err)
+		t.Fail()
+	}
+	if err = toml.Set("h", []interface{}{}); err != nil || toml.Kind("h") != Array {
+		t.Log("an empty array should be set, err:", err)
+		t.Fail()
+	}
+	if err = toml.Set("e[0]", 1); err != nil || !reflect.DeepEqual(toml.GetIntArray("e"), []int{1}) {
+		t.Log("the first element should give the array its type, err:", err)
+		t.Fail()
+	}
+	if err = toml.Set("t[0].name", "a"); err != nil || toml.GetString("t[0].name", "") != "a" {
+		t.Log("an empty array should take a table, err:", err)
+		t.Fail()
+	}
+	if err = toml.Set("e[1]", "x"); err == nil {
+		t.Log("an array with elements should keep its type")
+		t.Fail()
+	}
+}
+
+func TestDelete(t *testing.T) {
+	toml, err := ParseString(example)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+	ports := toml.GetIntArray("database.ports")
+
+	if err := toml.Delete("database.ports[0]"); err != nil ||
+		!reflect.DeepEqual(toml.GetIntArray("database.ports"), []int{8001, 8002}) {
+		t.Log("Delete should remove the element. err:", err)
+		t.Fail()
+	}
+	if ports[0] != 8001 || len(ports) != 3 {
+		t.Log("Delete should not change a shared array:", ports)
+		t.Fail()
+	}
+	if err := toml.Delete("products[1]"); err != nil || toml.GetString("products[1].name", "") != "Nail" {
+		t.Log("Delete should remove the table. err:", err)
+		t.Fail()
+	}
+	if err := toml.Delete("owner.name"); err != nil || toml.GetString("owner.name", "") != "" {
+		t.Log("Delete should remove the key. err:", err)
+		t.Fail()
+	}
+	for _, path := range []string{"owner.name", "missing.key", "products[5]", "title.x"} {
+		if err := toml.Delete(path); err == nil {
+			t.Log("Delete should fail:", path)
+			t.Fail()
+		}
+	}
+}
+
+func TestAppendTable(t *testing.T) {
+	toml := NewToml()
+	first := toml.AppendTable("fruit.variety")
+	second := toml.AppendTable("fruit.variety")
+	if first == nil || second == nil {
+		t.Log("AppendTable should create the array of tables")
+		t.Fail()
+		return
+	}
+	second.Set("name", "plantain")
+	if toml.GetString("fruit.variety[1].name", "") != "plantain" {
+		t.Log("AppendTable should return the appended table")
+		t.Fail()
+	}
+
+	toml.Set("title", "TOML")
+	if toml.AppendTable("title") != nil || toml.AppendTable("fruit.variety[0]") != nil {
+		t.Log("AppendTable should not replace other values")
+		t.Fail()
+	}
+}
@@ -0,0 +1,186 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	errEnvValue  = errors.New("not a TOML value")
+	errEnvPrefix = errors.New("a prefix is required to apply environment variables")
+)
+
+func errUnknownKey(path []pathSegment) error {
+	return errors.New(fmt.Sprint("unknown key ", formatPath(path)))
+}
+
+func errKindMismatch(expected, got interface{}) error {
+	return errors.New(fmt.Sprint("expected ", typeName(expected), ", got ", typeName(got)))
+}
+
+//sameType tells whether val can replace old, an empty array parsed from a
+//document taking arrays of any type
+func sameType(old, val interface{}) bool {
+	if _, ok := old.([]interface{}); ok {
+		return kindOf(val) == Array
+	}
+	return reflect.TypeOf(val) == reflect.TypeOf(old)
+}
+
+//the kind of v, and of its elements for arrays
+func typeName(v interface{}) string {
+	if kindOf(v) != Array {
+		return kindOf(v).String()
+	}
+	if _, ok := v.([]interface{}); ok {
+		return "empty array"
+	}
+	elem := reflect.Zero(reflect.TypeOf(v).Elem()).Interface()
+	return fmt.Sprint("array of ", kindOf(elem))
+}
+
+func errEnvVar(name string, err error) error {
+	return errors.New(fmt.Sprint(name, ": ", err))
+}
+
+type EnvOptions struct {
+	//only the variables starting with Prefix apply, as APP_. It is required,
+	//the other variables of the process having nothing to do with the document
+	Prefix string
+	//separates the keys of a path, "__" by default, so that
+	//APP_DATABASE__CONNECTION_MAX targets database.connection_max
+	Separator string
+	//the variables as NAME=value, os.Environ() by default
+	Environ []string
+}
+
+//ApplyEnv overrides values of the document with environment variables. The
+//keys of the path a variable targets are matched regardless of case, numbers
+//index arrays of tables, as APP_SERVERS__0__PORT, and the value must already
+//exist. Variable values are read with the TOML value grammar and must have
+//the type of the value they replace, strings may be written without quotes
+//and integers replace floats.
+//
+//Either every variable applies, or the document is left untouched and the
+//error reports every variable that could not. The variable a value came from
+//is recorded as its origin, see Origin. An empty or nil opts is an error, the
+//prefix being required.
+func (t *Toml) ApplyEnv(opts *EnvOptions) error {
+	if t.frozen {
+		return errFrozen
+	}
+	if opts == nil || len(opts.Prefix) == 0 {
+		return errEnvPrefix
+	}
+	sep := opts.Separator
+	if len(sep) == 0 {
+		sep = "__"
+	}
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	environ = append([]string(nil), environ...)
+	sort.Strings(environ)
+
+	work := copyTable(t)
+	origins := make(map[string]string, len(t.origins))
+	for path, origin := range t.origins {
+		origins[path] = origin
+	}
+	var errs []error
+	for _, v := range environ {
+		name, raw, ok := strings.Cut(v, "=")
+		if !ok || !strings.HasPrefix(name, opts.Prefix) || len(name) == len(opts.Prefix) {
+			continue
+		}
+		segs, err := work.setEnv(name[len(opts.Prefix):], sep, raw)
+		if err != nil {
+			errs = append(errs, errEnvVar(name, err))
+			continue
+		}
+		origins[formatPath(segs)] = name
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	t.dict, t.order, t.origins = work.dict, work.order, origins
+	return nil
+}
+
+func (t *Toml) setEnv(name, sep, raw string) (segs []pathSegment, err error) {
+	var cur interface{} = t
+	for _, part := range strings.Split(name, sep) {
+		switch v := cur.(type) {
+		case *Toml:
+			key, ok := v.foldKey(part)
+			if !ok {
+				return nil, errUnknownKey(appendKey(segs, strings.ToLower(part)))
+			}
+			segs, cur = appendKey(segs, key), v.dict[key]
+		case []*Toml:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 {
+				return nil, errUnknownKey(appendKey(segs, strings.ToLower(part)))
+			}
+			if i >= len(v) {
+				return nil, errUnknownKey(appendIndex(segs, i))
+			}
+			segs, cur = appendIndex(segs, i), v[i]
+		default:
+			return nil, errUnknownKey(appendKey(segs, strings.ToLower(part)))
+		}
+	}
+
+	val, err := envValue(raw, cur)
+	if err != nil {
+		return
+	}
+	_, err = rewrite(t, segs, false, func(parent interface{}, seg pathSegment) (interface{}, error) {
+		return setChild(parent, seg, val)
+	})
+	return
+}
+
+//foldKey finds the key matching name regardless of case, the lower case key
+//first
+func (t *Toml) foldKey(name string) (string, bool) {
+	if lower := strings.ToLower(name); t.dict[lower] != nil {
+		return lower, true
+	}
+	for _, key := range t.sortedKeys() {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+//envValue reads raw as a value of the type of old
+func envValue(raw string, old interface{}) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	kind := kindOf(old)
+	if kind == String && (len(raw) == 0 || raw[0] != '"' && raw[0] != '\'') {
+		return raw, nil
+	}
+
+	val, idx, err := extractValue([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil || idx != len(raw) {
+		return nil, errEnvValue
+	}
+	if i, ok := val.(int); ok && kind == Float {
+		val = float64(i)
+	}
+	if !sameType(old, val) {
+		return nil, errKindMismatch(old, val)
+	}
+	return val, nil
+}
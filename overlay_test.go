@@ -0,0 +1,92 @@
+package fiptoml
+
+import (
+	"strings"
+	"testing"
+)
+
+const overlayExample = `title = "app"
+ratio = 0.5
+tags = [ "a", "b" ]
+
+[database]
+connection_max = 5000
+enabled = true
+ports = [ 8001, 8002 ]
+
+[[servers]]
+name = "alpha"
+port = 80
+`
+
+func TestApplyEnv(t *testing.T) {
+	toml, _ := ParseString(overlayExample)
+	err := toml.ApplyEnv(&EnvOptions{Prefix: "APP_", Environ: []string{
+		"APP_DATABASE__CONNECTION_MAX=100",
+		"APP_DATABASE__ENABLED=false",
+		"APP_DATABASE__PORTS=[ 9001, 9002, 9003 ]",
+		"APP_TITLE=my app",
+		"APP_RATIO=2",
+		"APP_TAGS=[\"x\"]",
+		"APP_SERVERS__0__PORT=8080",
+		"HOME=/root",
+		"APP_=ignored",
+	}})
+	if err != nil {
+		t.Fatal("ApplyEnv should work. err:", err)
+	}
+	if toml.GetInt("database.connection_max", 0) != 100 || toml.GetBool("database.enabled", true) ||
+		len(toml.GetIntArray("database.ports")) != 3 || toml.GetString("title", "") != "my app" ||
+		toml.GetFloat("ratio", 0) != 2 || toml.GetStringArray("tags")[0] != "x" ||
+		toml.GetInt("servers[0].port", 0) != 8080 {
+		t.Log("variables should override values, keeping their types")
+		t.Fail()
+	}
+	if toml.Origin("database.connection_max") != "APP_DATABASE__CONNECTION_MAX" {
+		t.Log("the variable should be recorded as origin, got", toml.Origin("database.connection_max"))
+		t.Fail()
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	toml, _ := ParseString(overlayExample)
+	before := toml.Clone()
+	err := toml.ApplyEnv(&EnvOptions{Prefix: "APP_", Environ: []string{
+		"APP_TITLE=fine",
+		"APP_DATABASE__CONNECTION_MAX=many",
+		"APP_DATABASE__PORTS=[ \"a\" ]",
+		"APP_DATABASE__HOST=localhost",
+		"APP_SERVERS__3__PORT=1",
+		"APP_DATABASE=1",
+	}})
+	expected := []string{
+		"APP_DATABASE: expected table, got integer",
+		"APP_DATABASE__CONNECTION_MAX: not a TOML value",
+		"APP_DATABASE__HOST: unknown key database.host",
+		"APP_DATABASE__PORTS: expected array of integer, got array of string",
+		"APP_SERVERS__3__PORT: unknown key servers[3]",
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Log("every failing variable should be reported, got", err)
+		t.Fail()
+	}
+	if !Equal(toml, before) {
+		t.Log("the document should be left untouched")
+		t.Fail()
+	}
+}
+
+func TestApplyEnvPrefix(t *testing.T) {
+	toml, _ := ParseString(overlayExample)
+	before := toml.Clone()
+	for _, opts := range []*EnvOptions{nil, {}, {Environ: []string{"TITLE=other"}}} {
+		if err := toml.ApplyEnv(opts); err == nil {
+			t.Log("a prefix should be required, opts:", opts)
+			t.Fail()
+		}
+	}
+	if !Equal(toml, before) {
+		t.Log("the document should be left untouched")
+		t.Fail()
+	}
+}
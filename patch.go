@@ -0,0 +1,166 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	errTestFailed = errors.New("test failed")
+	errUnknownOp  = errors.New("unknown operation")
+	errMoveInto   = errors.New("a value cannot be moved into itself")
+)
+
+func errPatch(i int, op PatchOp, err error) error {
+	return errors.New(fmt.Sprint("patch operation ", i, " (", op.Op, " ", op.Path, "): ", err))
+}
+
+//PatchOp is an operation of a patch, after JSON Patch (RFC 6902) with paths
+//in the syntax of GetPath:
+//
+//	add      sets the value at Path, inserting it when Path is an array index
+//	remove   removes the value at Path
+//	replace  replaces the value at Path, which must exist
+//	move     removes the value at From and adds it at Path, which cannot be
+//	         under From
+//	copy     adds a copy of the value at From at Path
+//	test     checks that the value at Path equals Value
+type PatchOp struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+//ApplyPatch applies the operations in order. Either all of them apply, or the
+//document is left untouched and the error names the failing operation. On
+//success the tables of the document are replaced by patched copies, tables
+//taken from it before are not updated.
+func (t *Toml) ApplyPatch(ops []PatchOp) error {
+	if t.frozen {
+		return errFrozen
+	}
+	work := copyTable(t)
+	work.origins = make(map[string]string, len(t.origins))
+	for path, origin := range t.origins {
+		work.origins[path] = origin
+	}
+	for i, op := range ops {
+		if err := work.applyOp(op); err != nil {
+			return errPatch(i, op, err)
+		}
+	}
+	t.dict, t.order, t.origins = work.dict, work.order, work.origins
+	return nil
+}
+
+func (t *Toml) applyOp(op PatchOp) (err error) {
+	switch op.Op {
+	case "add":
+		return t.add(op.Path, op.Value)
+	case "remove":
+		return t.Delete(op.Path)
+	case "replace":
+		if _, err = t.GetPath(op.Path); err != nil {
+			return
+		}
+		return t.Set(op.Path, op.Value)
+	case "move", "copy":
+		v, err := t.GetPath(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if err = t.checkMove(op.From, op.Path); err == nil {
+				err = t.Delete(op.From)
+			}
+		} else {
+			v = copyValue(v)
+		}
+		if err != nil {
+			return err
+		}
+		return t.add(op.Path, v)
+	case "test":
+		v, err := t.GetPath(op.Path)
+		if err != nil {
+			return err
+		}
+		expected, err := normalizeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		if !valuesEqual(v, expected) {
+			return errTestFailed
+		}
+		return nil
+	default:
+		return errUnknownOp
+	}
+}
+
+//checkMove fails if path is under from, so that a table is not moved into
+//itself
+func (t *Toml) checkMove(from, path string) error {
+	fromSegs, err := parsePath(from)
+	if err != nil {
+		return err
+	}
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	abs := formatPath(t.absPath(fromSegs))
+	if to := formatPath(t.absPath(segs)); to != abs && isPathPrefix(abs, to) {
+		return errMoveInto
+	}
+	return nil
+}
+
+//add is Set, except that array elements are inserted rather than replaced
+func (t *Toml) add(path string, v interface{}) (err error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return
+	}
+	val, err := normalizeValue(v)
+	if err != nil {
+		return
+	}
+
+	segs = t.absPath(segs)
+	inserted := false
+	_, err = rewrite(t, segs, true, func(parent interface{}, seg pathSegment) (interface{}, error) {
+		if !seg.isIndex || parent == nil {
+			return setChild(parent, seg, val)
+		}
+		inserted = true
+		return insertChild(parent, seg.index, val)
+	})
+	if err == nil && inserted {
+		t.moveOrigins(segs, 1)
+	}
+	return
+}
+
+func insertChild(parent interface{}, i int, val interface{}) (interface{}, error) {
+	elem := reflect.ValueOf(val)
+	array := reflect.ValueOf(typedArray(parent, elem))
+	if array.Kind() != reflect.Slice || array.Type().Elem() != elem.Type() {
+		return nil, errTypeMismatch
+	}
+	if i != array.Len() {
+		var ok bool
+		if i, ok = arrayIndex(i, array.Len()); !ok {
+			return nil, errValueNotFound
+		}
+	}
+
+	//a new array, the old one may be shared
+	inserted := reflect.MakeSlice(array.Type(), 0, array.Len()+1)
+	inserted = reflect.AppendSlice(inserted, array.Slice(0, i))
+	inserted = reflect.Append(inserted, elem)
+	inserted = reflect.AppendSlice(inserted, array.Slice(i, array.Len()))
+	return inserted.Interface(), nil
+}
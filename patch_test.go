@@ -0,0 +1,97 @@
+package fiptoml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	toml, _ := ParseString(example)
+	err := toml.ApplyPatch([]PatchOp{
+		{Op: "test", Path: "database.server", Value: "192.168.1.1"},
+		{Op: "replace", Path: "database.server", Value: "10.0.0.1"},
+		{Op: "add", Path: "database.ports[0]", Value: 8000},
+		{Op: "add", Path: "database.timeout", Value: 30},
+		{Op: "remove", Path: "products[1]"},
+		{Op: "copy", From: "products[0]", Path: "products[2]"},
+		{Op: "replace", Path: "products[2].name", Value: "Saw"},
+		{Op: "move", From: "owner.tags", Path: "tags"},
+	})
+	if err != nil {
+		t.Log("ApplyPatch should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	if toml.GetString("database.server", "") != "10.0.0.1" ||
+		toml.GetInt("database.timeout", 0) != 30 ||
+		!reflect.DeepEqual(toml.GetIntArray("database.ports"), []int{8000, 8001, 8001, 8002}) {
+		t.Log("ApplyPatch database:", toml.dict["database"])
+		t.Fail()
+	}
+	if toml.GetString("products[1].name", "") != "Nail" || toml.GetString("products[2].name", "") != "Saw" ||
+		toml.GetString("products[0].name", "") != "Hammer" {
+		t.Log("ApplyPatch products:", toml.dict["products"])
+		t.Fail()
+	}
+	if toml.Has("owner.tags") || len(toml.GetStringArray("tags")) != 2 {
+		t.Log("ApplyPatch should move the tags")
+		t.Fail()
+	}
+}
+
+func TestApplyPatchAtomic(t *testing.T) {
+	toml, _ := ParseString(example)
+	before, _ := ParseString(example)
+
+	cases := []struct {
+		op  PatchOp
+		msg string
+	}{
+		{PatchOp{Op: "test", Path: "title", Value: "other"}, "patch operation 2 (test title): test failed"},
+		{PatchOp{Op: "replace", Path: "missing", Value: 1}, "patch operation 2 (replace missing)"},
+		{PatchOp{Op: "remove", Path: "products[3]"}, "patch operation 2 (remove products[3])"},
+		{PatchOp{Op: "add", Path: "files[1]", Value: 1}, "patch operation 2 (add files[1])"},
+		{PatchOp{Op: "move", From: "missing", Path: "title"}, "patch operation 2 (move title)"},
+		{PatchOp{Op: "move", From: "database", Path: "database.sub"}, "patch operation 2 (move database.sub): a value cannot be moved into itself"},
+		{PatchOp{Op: "move", From: "products[-1]", Path: "products[2].copy"}, "a value cannot be moved into itself"},
+		{PatchOp{Op: "frobnicate", Path: "title"}, "unknown operation"},
+	}
+	for _, c := range cases {
+		err := toml.ApplyPatch([]PatchOp{
+			{Op: "replace", Path: "title", Value: "patched"},
+			{Op: "remove", Path: "database.ports[0]"},
+			c.op,
+		})
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Log("ApplyPatch should fail with:", c.msg, "err:", err)
+			t.Fail()
+		}
+		if len(Diff(before, toml)) != 0 {
+			t.Log("failed ApplyPatch should leave the document untouched:", Diff(before, toml))
+			t.Fail()
+		}
+	}
+}
+
+func TestApplyPatchEmptyArray(t *testing.T) {
+	toml, _ := ParseString("e = []\nf = []\n")
+	err := toml.ApplyPatch([]PatchOp{
+		{Op: "test", Path: "e", Value: []interface{}{}},
+		{Op: "test", Path: "e", Value: []int{}},
+		{Op: "copy", From: "e", Path: "copied"},
+		{Op: "move", From: "f", Path: "moved"},
+		{Op: "add", Path: "e[0]", Value: 1},
+		{Op: "add", Path: "copied[0]", Value: "a"},
+	})
+	if err != nil {
+		t.Fatal("ApplyPatch should work on empty arrays. err:", err)
+	}
+	if !reflect.DeepEqual(toml.GetIntArray("e"), []int{1}) ||
+		!reflect.DeepEqual(toml.GetStringArray("copied"), []string{"a"}) ||
+		toml.Kind("moved") != Array || toml.Has("f") {
+		t.Log("ApplyPatch empty arrays:", toml.dict)
+		t.Fail()
+	}
+}
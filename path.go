@@ -0,0 +1,286 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Paths address values inside a document. A path is a list of segments:
+
+	title                 key of the root table
+	owner.name            key of a nested table
+	products[1].name      element of an array or array of tables
+	products[-1]          negative indices count from the end
+	"127.0.0.1".host      quoted keys may contain dots, brackets or spaces
+	'C:\temp'.size        literal quoted keys take no escapes
+
+Bare keys are any run of characters other than '.', '[', ']', quotes and
+white space. Basic quoted keys use the same escapes as TOML basic strings.
+*/
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func errInvalidPath(path string, pos int) error {
+	return errors.New(fmt.Sprint("invalid path: ", path, " at ", pos))
+}
+
+type pathScanner struct {
+	path string
+	pos  int
+	stop string //extra bytes ending a bare key
+}
+
+func (s *pathScanner) done() bool {
+	return s.pos >= len(s.path)
+}
+
+func (s *pathScanner) peek() byte {
+	if s.done() {
+		return 0
+	}
+	return s.path[s.pos]
+}
+
+func (s *pathScanner) fail() error {
+	return errInvalidPath(s.path, s.pos)
+}
+
+//bare, "basic" or 'literal' key
+func (s *pathScanner) key() (key string, err error) {
+	switch s.peek() {
+	case '"':
+		i := s.pos + 1
+		for i < len(s.path) && s.path[i] != '"' {
+			if s.path[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(s.path) {
+			return "", s.fail()
+		}
+		key, _, err = unescape([]byte(s.path[s.pos+1:i]), false)
+		if err != nil {
+			return "", s.fail()
+		}
+		s.pos = i + 1
+	case '\'':
+		end := strings.IndexByte(s.path[s.pos+1:], '\'')
+		if end < 0 {
+			return "", s.fail()
+		}
+		key = s.path[s.pos+1 : s.pos+1+end]
+		s.pos += end + 2
+	default:
+		from := s.pos
+		for !s.done() && isBareKeyByte(s.peek()) && strings.IndexByte(s.stop, s.peek()) < 0 {
+			s.pos++
+		}
+		if s.pos == from {
+			return "", s.fail()
+		}
+		key = s.path[from:s.pos]
+	}
+	return
+}
+
+//index between brackets, the '[' is already consumed
+func (s *pathScanner) index() (i int, err error) {
+	from := s.pos
+	if s.peek() == '-' {
+		s.pos++
+	}
+	for !s.done() && s.peek() >= '0' && s.peek() <= '9' {
+		s.pos++
+	}
+	if s.peek() != ']' {
+		return 0, s.fail()
+	}
+	i, err = strconv.Atoi(s.path[from:s.pos])
+	if err != nil {
+		return 0, errInvalidPath(s.path, from)
+	}
+	s.pos++
+	return
+}
+
+func isBareKeyByte(b byte) bool {
+	switch b {
+	case '.', '[', ']', '"', '\'', ' ', '\t', '\n', '\r', '\f':
+		return false
+	default:
+		return true
+	}
+}
+
+func parsePath(path string) (segs []pathSegment, err error) {
+	if len(path) == 0 {
+		return nil, errNoKey
+	}
+
+	s := &pathScanner{path: path}
+	key, err := s.key()
+	if err != nil {
+		return
+	}
+	segs = append(segs, pathSegment{key: key})
+
+	for !s.done() {
+		switch s.peek() {
+		case '.':
+			s.pos++
+			key, err = s.key()
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, pathSegment{key: key})
+		case '[':
+			s.pos++
+			i, err := s.index()
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, pathSegment{index: i, isIndex: true})
+		default:
+			return nil, s.fail()
+		}
+	}
+	return
+}
+
+func formatPath(segs []pathSegment) string {
+	return writePath(segs, false)
+}
+
+//formatPattern writes every index as [*]
+func formatPattern(segs []pathSegment) string {
+	return writePath(segs, true)
+}
+
+func writePath(segs []pathSegment, wildcard bool) string {
+	var b strings.Builder
+	for i, seg := range segs {
+		if seg.isIndex && wildcard {
+			b.WriteString("[*]")
+			continue
+		} else if seg.isIndex {
+			fmt.Fprint(&b, "[", seg.index, "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(quoteKey(seg.key))
+	}
+	return b.String()
+}
+
+func quoteKey(key string) string {
+	if len(key) == 0 {
+		return `""`
+	}
+	for i := 0; i < len(key); i++ {
+		if !isBareKeyByte(key[i]) || key[i] < 0x20 || key[i] == '\\' {
+			return encodeString(key)
+		}
+	}
+	return key
+}
+
+//copies so that sibling paths never share a backing array
+func appendKey(segs []pathSegment, key string) []pathSegment {
+	return append(segs[:len(segs):len(segs)], pathSegment{key: key})
+}
+
+func appendIndex(segs []pathSegment, i int) []pathSegment {
+	return append(segs[:len(segs):len(segs)], pathSegment{index: i, isIndex: true})
+}
+
+//GetPath returns the raw value at path: a string, bool, int, float64,
+//time.Time, one of their slices, *Toml or []*Toml.
+func (t *Toml) GetPath(path string) (val interface{}, err error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return
+	}
+	if val, err = resolve(t, segs); err != nil {
+		return
+	}
+	return t.shared(val), nil
+}
+
+//absPath replaces the negative indices of segs with the indices they stand
+//for in the document
+func (t *Toml) absPath(segs []pathSegment) []pathSegment {
+	abs := make([]pathSegment, len(segs))
+	var cur interface{} = t
+	for i, seg := range segs {
+		if seg.isIndex && seg.index < 0 {
+			if rv := reflect.ValueOf(cur); rv.Kind() == reflect.Slice {
+				seg.index += rv.Len()
+			}
+		}
+		abs[i] = seg
+		cur, _ = child(cur, seg)
+	}
+	return abs
+}
+
+func resolve(val interface{}, segs []pathSegment) (interface{}, error) {
+	var err error
+	for _, seg := range segs {
+		val, err = child(val, seg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func child(val interface{}, seg pathSegment) (interface{}, error) {
+	if !seg.isIndex {
+		table, ok := val.(*Toml)
+		if !ok {
+			return nil, errValueNotFound
+		}
+		v, ok := table.dict[seg.key]
+		if !ok {
+			return nil, errValueNotFound
+		}
+		return v, nil
+	}
+
+	if array, ok := val.([]*Toml); ok {
+		i, ok := arrayIndex(seg.index, len(array))
+		if !ok {
+			return nil, errValueNotFound
+		}
+		return array[i], nil
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice {
+		return nil, errValueNotFound
+	}
+	i, ok := arrayIndex(seg.index, rv.Len())
+	if !ok {
+		return nil, errValueNotFound
+	}
+	return rv.Index(i).Interface(), nil
+}
+
+//negative indices count from the end
+func arrayIndex(i, length int) (int, bool) {
+	if i < 0 {
+		i += length
+	}
+	return i, i >= 0 && i < length
+}
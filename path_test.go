@@ -0,0 +1,83 @@
+package fiptoml
+
+import (
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		path string
+		segs []pathSegment
+	}{
+		{`title`, []pathSegment{{key: "title"}}},
+		{`owner.name`, []pathSegment{{key: "owner"}, {key: "name"}}},
+		{`products[1].name`, []pathSegment{{key: "products"}, {index: 1, isIndex: true}, {key: "name"}}},
+		{`products[-1]`, []pathSegment{{key: "products"}, {index: -1, isIndex: true}}},
+		{`"127.0.0.1".host`, []pathSegment{{key: "127.0.0.1"}, {key: "host"}}},
+		{`'C:\temp'.size`, []pathSegment{{key: `C:\temp`}, {key: "size"}}},
+		{`"a \"b\""[0][1]`, []pathSegment{{key: `a "b"`}, {index: 0, isIndex: true}, {index: 1, isIndex: true}}},
+		{`"tab\there \u00e9\u0001"`, []pathSegment{{key: "tab\there \u00e9\x01"}}},
+	}
+	for _, c := range cases {
+		segs, err := parsePath(c.path)
+		if err != nil || len(segs) != len(c.segs) {
+			t.Log("parsePath:", c.path, "segs:", segs, "err:", err)
+			t.Fail()
+			continue
+		}
+		for i := range segs {
+			if segs[i] != c.segs[i] {
+				t.Log("parsePath:", c.path, "segment", i, "is", segs[i], "want", c.segs[i])
+				t.Fail()
+			}
+		}
+		again, err := parsePath(formatPath(segs))
+		if err != nil || formatPath(again) != formatPath(segs) {
+			t.Log("formatPath should round trip:", c.path, formatPath(segs), "err:", err)
+			t.Fail()
+		}
+	}
+
+	for _, path := range []string{``, `.a`, `a.`, `a..b`, `a[`, `a[]`, `a[x]`, `a[1`, `a]`, `"a`, `'a`, `a b`, `"\x41"`, `"\a"`, `"\101"`, `"\'"`} {
+		if _, err := parsePath(path); err == nil {
+			t.Log("parsePath should fail for:", path)
+			t.Fail()
+		}
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	toml, err := ParseString(`127.0.0.1 = "localhost"
+	ports = [ 8001, 8002 ]
+
+	[[products]]
+	name = "Hammer"
+
+	[[products]]
+	name = "Nail"
+	`)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	if v, err := toml.GetPath(`"127.0.0.1"`); err != nil || v != "localhost" {
+		t.Log("GetPath quoted key:", v, "err:", err)
+		t.Fail()
+	}
+	if v, err := toml.GetPath("products[1].name"); err != nil || v != "Nail" {
+		t.Log("GetPath products[1].name:", v, "err:", err)
+		t.Fail()
+	}
+	if v, err := toml.GetPath("ports[-2]"); err != nil || v != 8001 {
+		t.Log("GetPath ports[-2]:", v, "err:", err)
+		t.Fail()
+	}
+	for _, path := range []string{"ports[2]", "ports[-3]", "products[0].sku", "ports.name", "missing.key"} {
+		if _, err := toml.GetPath(path); err != errValueNotFound {
+			t.Log("GetPath should not find:", path, "err:", err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//the top level table holding the profiles
+const profileKey = "profile"
+
+var errProfileExtends = errors.New("extends should be the name of a profile")
+
+func errUnknownProfile(name string) error {
+	return errors.New(fmt.Sprint("unknown profile ", name))
+}
+
+func errProfileCycle(chain []string) error {
+	return errors.New(fmt.Sprint("profile cycle: ", strings.Join(chain, " -> ")))
+}
+
+//LoadProfile loads the file at path with the profile name selected, see
+//Options.Profile.
+func LoadProfile(path, name string) (doc *Toml, err error) {
+	return LoadWith(path, &Options{Profile: name})
+}
+
+//SelectProfile returns a new document with the profile name merged over the
+//top level of t, and the profiles removed, see Options.Profile.
+func (t *Toml) SelectProfile(name string) (*Toml, error) {
+	profiles, _ := t.dict[profileKey].(*Toml)
+	if profiles == nil {
+		return nil, errUnknownProfile(name)
+	}
+
+	//the profile and the ones it extends, in that order
+	var chain []string
+	for cur := name; ; {
+		for _, n := range chain {
+			if n == cur {
+				return nil, errProfileCycle(append(chain, cur))
+			}
+		}
+		profile, ok := profiles.dict[cur].(*Toml)
+		if !ok {
+			return nil, errUnknownProfile(cur)
+		}
+		chain = append(chain, cur)
+
+		extends, ok := profile.dict["extends"]
+		if !ok {
+			break
+		}
+		if cur, ok = extends.(string); !ok {
+			return nil, errProfileExtends
+		}
+	}
+
+	result := NewToml()
+	result.name, result.origins = t.name, make(map[string]string, len(t.origins))
+	for _, key := range t.orderedKeys() {
+		if key != profileKey {
+			result.set(key, t.dict[key])
+		}
+	}
+	for path, origin := range t.origins {
+		if !isPathPrefix(profileKey, path) {
+			result.origins[path] = origin
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		result = Merge(result, t.profileLayer(chain[i], profiles.dict[chain[i]].(*Toml)), nil)
+	}
+	result.name = t.name
+	return result, nil
+}
+
+//profileLayer is the profile without its extends key, with the origins of its
+//values
+func (t *Toml) profileLayer(name string, profile *Toml) *Toml {
+	layer := NewToml()
+	for _, key := range profile.orderedKeys() {
+		if key != "extends" {
+			layer.set(key, profile.dict[key])
+		}
+	}
+	prefix := appendKey(appendKey(nil, profileKey), name)
+	layer.name = t.originOf(prefix, t.name)
+	layer.origins = make(map[string]string)
+	p := formatPath(prefix)
+	for path, origin := range t.origins {
+		if isPathPrefix(p, path) && len(path) > len(p) && path[len(p)] == '.' {
+			layer.origins[path[len(p)+1:]] = origin
+		}
+	}
+	return layer
+}
@@ -0,0 +1,123 @@
+package fiptoml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profileExample = `title = "app"
+
+[database]
+host = "localhost"
+port = 5432
+pool = 5
+
+[profile.base.database]
+pool = 20
+
+[profile.staging]
+extends = "base"
+
+[profile.staging.database]
+host = "db.staging"
+
+[profile.prod]
+extends = "staging"
+log = "warn"
+
+[profile.prod.database]
+host = "db.prod"
+`
+
+func TestSelectProfile(t *testing.T) {
+	toml, _ := ParseString(profileExample)
+	prod, err := toml.SelectProfile("prod")
+	if err != nil {
+		t.Fatal("SelectProfile should work. err:", err)
+	}
+	if prod.GetString("database.host", "") != "db.prod" || prod.GetInt("database.pool", 0) != 20 ||
+		prod.GetInt("database.port", 0) != 5432 || prod.GetString("log", "") != "warn" ||
+		prod.GetString("title", "") != "app" {
+		t.Log("the profile and the ones it extends should be merged over the document")
+		t.Fail()
+	}
+	if prod.Has("profile") || prod.Has("extends") {
+		t.Log("the profiles should be removed")
+		t.Fail()
+	}
+	if !toml.Has("profile.prod") || toml.GetString("database.host", "") != "localhost" {
+		t.Log("the document should not be modified")
+		t.Fail()
+	}
+
+	staging, _ := toml.SelectProfile("staging")
+	if staging.GetString("database.host", "") != "db.staging" || staging.Has("log") {
+		t.Log("a profile should not get the values of the profiles extending it")
+		t.Fail()
+	}
+}
+
+func TestSelectProfileInvalid(t *testing.T) {
+	cases := map[string]string{
+		"[profile.a]\nextends = \"b\"\n[profile.b]\nextends = \"a\"": "profile cycle: a -> b -> a",
+		"[profile.a]\nextends = \"a\"":                                "profile cycle: a -> a",
+		"[profile.a]\nextends = \"c\"":                                "unknown profile c",
+		"[profile.a]\nextends = 1":                                    "extends should be the name of a profile",
+		"title = \"app\"":                                             "unknown profile a",
+	}
+	for input, msg := range cases {
+		toml, _ := ParseString(input)
+		if _, err := toml.SelectProfile("a"); err == nil || err.Error() != msg {
+			t.Logf("%q: expected %q, got %v", input, msg, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.toml")
+	os.WriteFile(path, []byte("url = \"${database.host}:${database.port}\"\n"+profileExample), 0644)
+
+	toml, err := LoadWith(path, &Options{Profile: "prod", ResolveRefs: true})
+	if err != nil {
+		t.Fatal("LoadWith should work. err:", err)
+	}
+	if toml.GetString("url", "") != "db.prod:5432" {
+		t.Log("references should be resolved after the profile is selected, got", toml.GetString("url", ""))
+		t.Fail()
+	}
+	if toml.Origin("database.pool") != path {
+		t.Log("the origin of profile values should be their file, got", toml.Origin("database.pool"))
+		t.Fail()
+	}
+
+	_, err = LoadProfile(path, "dev")
+	if err == nil || !strings.HasPrefix(err.Error(), path+": unknown profile dev") {
+		t.Log("an unknown profile should fail naming the file, got", err)
+		t.Fail()
+	}
+}
+
+func ExampleLoadProfile() {
+	dir, _ := os.MkdirTemp("", "profile")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.toml")
+	os.WriteFile(path, []byte(`[database]
+host = "localhost"
+
+[profile.prod.database]
+host = "db.prod"`), 0644)
+
+	toml, err := LoadProfile(path, "prod")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(toml.GetString("database.host", ""), toml.Has("profile"))
+	// Output:
+	// db.prod false
+}
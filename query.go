@@ -0,0 +1,452 @@
+package fiptoml
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+Queries extend paths with wildcards, recursive descent and filters:
+
+	servers.*.port                    port of every table under servers
+	products[*].name                  name of every product
+	..port                            every port key at any depth
+	products[?(@.sku > 1000)].name    names of the products with a big sku
+	products[?(@.color)]              products that have a color
+	ports[?(@ >= 8000 && @ < 9000)]   elements of a plain array
+
+A filter compares a path relative to the candidate, '@', with a literal
+written in TOML syntax using one of == != < <= > >=, or only tests that the
+path exists. A candidate lacking the path matches no comparison. Terms are
+joined with && and ||, && binding tighter.
+*/
+
+//Match is a value selected by a query and the concrete path leading to it.
+type Match struct {
+	Path  string
+	Value Value
+}
+
+type queryNode struct {
+	segs []pathSegment
+	val  interface{}
+}
+
+type queryStep func(nodes []queryNode) []queryNode
+
+//one comparison of a filter, op is empty when only testing existence
+type filterTerm struct {
+	segs []pathSegment
+	op   string
+	lit  interface{}
+}
+
+//terms joined by || of terms joined by &&
+type filter [][]filterTerm
+
+//Query returns the values matching expr in document order, keys of a table
+//being visited in sorted order.
+func (t *Toml) Query(expr string) (matches []Match, err error) {
+	steps, err := parseQuery(expr)
+	if err != nil {
+		return
+	}
+
+	nodes := []queryNode{{val: t}}
+	for _, step := range steps {
+		nodes = step(nodes)
+	}
+	for _, n := range nodes {
+		matches = append(matches, Match{formatPath(n.segs), Value{t.shared(n.val)}})
+	}
+	return
+}
+
+func parseQuery(expr string) (steps []queryStep, err error) {
+	if len(expr) == 0 {
+		return nil, errNoKey
+	}
+
+	s := &pathScanner{path: expr}
+	if !strings.HasPrefix(expr, "..") {
+		step, err := parseQueryKey(s)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	for !s.done() {
+		var step queryStep
+		switch {
+		case strings.HasPrefix(s.path[s.pos:], ".."):
+			s.pos += 2
+			if s.peek() == '[' {
+				return nil, s.fail()
+			}
+			step, err = parseQueryKey(s)
+			step = recursiveStep(step)
+		case s.peek() == '.':
+			s.pos++
+			step, err = parseQueryKey(s)
+		case s.peek() == '[':
+			s.pos++
+			step, err = parseSelector(s)
+		default:
+			err = s.fail()
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return
+}
+
+func parseQueryKey(s *pathScanner) (queryStep, error) {
+	if s.peek() == '*' {
+		s.pos++
+		return wildcardStep, nil
+	}
+	key, err := s.key()
+	if err != nil {
+		return nil, err
+	}
+	return keyStep(key), nil
+}
+
+//selector between brackets, the '[' is already consumed
+func parseSelector(s *pathScanner) (queryStep, error) {
+	switch {
+	case strings.HasPrefix(s.path[s.pos:], "*]"):
+		s.pos += 2
+		return wildcardStep, nil
+	case strings.HasPrefix(s.path[s.pos:], "?("):
+		s.pos += 2
+		f, err := parseFilter(s)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(s.path[s.pos:], ")]") {
+			return nil, s.fail()
+		}
+		s.pos += 2
+		return filterStep(f), nil
+	default:
+		i, err := s.index()
+		if err != nil {
+			return nil, err
+		}
+		return indexStep(i), nil
+	}
+}
+
+func parseFilter(s *pathScanner) (f filter, err error) {
+	var and []filterTerm
+	for {
+		skipPathSpaces(s)
+		term, err := parseFilterTerm(s)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, term)
+
+		skipPathSpaces(s)
+		rest := s.path[s.pos:]
+		switch {
+		case strings.HasPrefix(rest, "&&"):
+			s.pos += 2
+		case strings.HasPrefix(rest, "||"):
+			s.pos += 2
+			f = append(f, and)
+			and = nil
+		default:
+			f = append(f, and)
+			return f, nil
+		}
+	}
+}
+
+func parseFilterTerm(s *pathScanner) (term filterTerm, err error) {
+	if s.peek() != '@' {
+		return term, s.fail()
+	}
+	s.pos++
+
+	s.stop = "=!<>)&|"
+	defer func() { s.stop = "" }()
+	for s.peek() == '.' || s.peek() == '[' {
+		if s.peek() == '.' {
+			s.pos++
+			key, err := s.key()
+			if err != nil {
+				return term, err
+			}
+			term.segs = appendKey(term.segs, key)
+		} else {
+			s.pos++
+			i, err := s.index()
+			if err != nil {
+				return term, err
+			}
+			term.segs = appendIndex(term.segs, i)
+		}
+	}
+
+	skipPathSpaces(s)
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(s.path[s.pos:], op) {
+			term.op = op
+			s.pos += len(op)
+			break
+		}
+	}
+	if len(term.op) == 0 {
+		return
+	}
+
+	skipPathSpaces(s)
+	from := s.pos
+	lit := scanLiteral(s.path[from:])
+	if len(lit) == 0 {
+		return term, s.fail()
+	}
+	val, idx, err := extractValue([]byte(lit))
+	if err != nil || val == nil || idx != len(lit) {
+		return term, errInvalidPath(s.path, from)
+	}
+	term.lit = val
+	s.pos += len(lit)
+	return
+}
+
+//a quoted string, or everything up to a space or the end of the term
+func scanLiteral(in string) string {
+	if len(in) > 0 && (in[0] == '"' || in[0] == '\'') {
+		for i := 1; i < len(in); i++ {
+			if in[i] == '\\' && in[0] == '"' {
+				i++
+			} else if in[i] == in[0] {
+				return in[:i+1]
+			}
+		}
+		return ""
+	}
+	end := strings.IndexAny(in, " \t)&|")
+	if end < 0 {
+		return in
+	}
+	return in[:end]
+}
+
+func skipPathSpaces(s *pathScanner) {
+	for s.peek() == ' ' || s.peek() == '\t' {
+		s.pos++
+	}
+}
+
+func keyStep(key string) queryStep {
+	return func(nodes []queryNode) (out []queryNode) {
+		for _, n := range nodes {
+			if table, ok := n.val.(*Toml); ok {
+				if v, ok := table.dict[key]; ok {
+					out = append(out, queryNode{appendKey(n.segs, key), v})
+				}
+			}
+		}
+		return
+	}
+}
+
+func indexStep(i int) queryStep {
+	seg := pathSegment{index: i, isIndex: true}
+	return func(nodes []queryNode) (out []queryNode) {
+		for _, n := range nodes {
+			if v, err := child(n.val, seg); err == nil {
+				j, _ := arrayIndex(i, reflect.ValueOf(n.val).Len())
+				out = append(out, queryNode{appendIndex(n.segs, j), v})
+			}
+		}
+		return
+	}
+}
+
+func wildcardStep(nodes []queryNode) (out []queryNode) {
+	for _, n := range nodes {
+		out = append(out, children(n)...)
+	}
+	return
+}
+
+func filterStep(f filter) queryStep {
+	return func(nodes []queryNode) (out []queryNode) {
+		for _, c := range wildcardStep(nodes) {
+			if f.match(c.val) {
+				out = append(out, c)
+			}
+		}
+		return
+	}
+}
+
+//applies step to every node and all of its descendants
+func recursiveStep(step queryStep) queryStep {
+	return func(nodes []queryNode) (out []queryNode) {
+		seen := make(map[string]bool)
+		for _, n := range nodes {
+			for _, m := range step(descendants(n, nil)) {
+				path := formatPath(m.segs)
+				if !seen[path] {
+					seen[path] = true
+					out = append(out, m)
+				}
+			}
+		}
+		return
+	}
+}
+
+func children(n queryNode) (out []queryNode) {
+	switch v := n.val.(type) {
+	case *Toml:
+		for _, key := range v.sortedKeys() {
+			out = append(out, queryNode{appendKey(n.segs, key), v.dict[key]})
+		}
+	case []*Toml:
+		for i, table := range v {
+			out = append(out, queryNode{appendIndex(n.segs, i), table})
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Slice {
+			for i := 0; i < rv.Len(); i++ {
+				out = append(out, queryNode{appendIndex(n.segs, i), rv.Index(i).Interface()})
+			}
+		}
+	}
+	return
+}
+
+func descendants(n queryNode, out []queryNode) []queryNode {
+	out = append(out, n)
+	for _, c := range children(n) {
+		out = descendants(c, out)
+	}
+	return out
+}
+
+func (f filter) match(val interface{}) bool {
+	for _, and := range f {
+		matched := true
+		for _, term := range and {
+			if !term.match(val) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (term filterTerm) match(val interface{}) bool {
+	v, err := resolve(val, term.segs)
+	if err != nil {
+		return false
+	}
+	if len(term.op) == 0 {
+		return true
+	}
+
+	c, ok := compareValues(v, term.lit)
+	switch term.op {
+	case "==":
+		return ok && c == 0
+	case "!=":
+		return !ok || c != 0
+	case "<":
+		return ok && c < 0
+	case "<=":
+		return ok && c <= 0
+	case ">":
+		return ok && c > 0
+	default:
+		return ok && c >= 0
+	}
+}
+
+//ok is false when a and b are not comparable, ints and floats compare as numbers
+func compareValues(a, b interface{}) (c int, ok bool) {
+	switch x := a.(type) {
+	case int:
+		switch y := b.(type) {
+		case int:
+			return compareInts(x, y), true
+		case float64:
+			return compareFloats(float64(x), y)
+		}
+	case float64:
+		switch y := b.(type) {
+		case int:
+			return compareFloats(x, float64(y))
+		case float64:
+			return compareFloats(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1, true
+			case x.After(y):
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	return 0, false
+}
+
+//NaN compares to nothing, not even NaN
+func compareFloats(x, y float64) (int, bool) {
+	switch {
+	case math.IsNaN(x) || math.IsNaN(y):
+		return 0, false
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func compareInts(x, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
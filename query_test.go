@@ -0,0 +1,141 @@
+package fiptoml
+
+import (
+	"fmt"
+	"testing"
+)
+
+const queryExample = `title = "Query"
+
+[servers.alpha]
+ip = "10.0.0.1"
+port = 8001
+
+[servers.beta]
+ip = "10.0.0.2"
+port = 8002
+
+[[products]]
+name = "Hammer"
+sku = 738
+
+[[products]]
+name = "Nail"
+sku = 284758393
+color = "gray"
+
+[[products]]
+name = "Screw"
+sku = 1001
+ports = [ 7000, 8000, 9000 ]
+`
+
+func ExampleToml_Query() {
+	toml, err := ParseString(queryExample)
+	if err != nil {
+		fmt.Println("ParseString should work")
+		return
+	}
+	matches, err := toml.Query("products[?(@.sku > 1000)].name")
+	if err != nil {
+		fmt.Println("Query should work")
+		return
+	}
+	for _, m := range matches {
+		fmt.Println(m.Path, "=", m.Value)
+	}
+	// Output:
+	// products[1].name = Nail
+	// products[2].name = Screw
+}
+
+func TestQuery(t *testing.T) {
+	toml, err := ParseString(queryExample)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	cases := []struct {
+		expr  string
+		paths []string
+	}{
+		{`title`, []string{`title`}},
+		{`servers.*.port`, []string{`servers.alpha.port`, `servers.beta.port`}},
+		{`..port`, []string{`servers.alpha.port`, `servers.beta.port`}},
+		{`..ports[*]`, []string{`products[2].ports[0]`, `products[2].ports[1]`, `products[2].ports[2]`}},
+		{`products[-1].ports[?(@ >= 8000 && @ < 9000)]`, []string{`products[2].ports[1]`}},
+		{`products[*].name`, []string{`products[0].name`, `products[1].name`, `products[2].name`}},
+		{`products[?(@.color)].name`, []string{`products[1].name`}},
+		{`products[?(@.name == "Hammer" || @.sku<=1001)].sku`, []string{`products[0].sku`, `products[2].sku`}},
+		{`products[?(@.name != "Nail")]`, []string{`products[0]`, `products[2]`}},
+		{`servers[?(@.ip == '10.0.0.2')].port`, []string{`servers.beta.port`}},
+		{`missing.*`, nil},
+	}
+	for _, c := range cases {
+		matches, err := toml.Query(c.expr)
+		if err != nil || len(matches) != len(c.paths) {
+			t.Log("Query:", c.expr, "matches:", matches, "err:", err)
+			t.Fail()
+			continue
+		}
+		for i, m := range matches {
+			if m.Path != c.paths[i] {
+				t.Log("Query:", c.expr, "match", i, "is", m.Path, "want", c.paths[i])
+				t.Fail()
+			}
+			if v, err := toml.GetPath(m.Path); err != nil || fmt.Sprint(v) != fmt.Sprint(m.Value.Interface()) {
+				t.Log("Query:", c.expr, "path", m.Path, "should lead to", m.Value, "err:", err)
+				t.Fail()
+			}
+		}
+	}
+
+	for _, expr := range []string{``, `..`, `a..[0]`, `a[?(@.b >)]`, `a[?(b)]`, `a[?(@.b == "x)]`, `a[?(@.b == x)]`, `a[?(@.b`} {
+		if _, err := toml.Query(expr); err == nil {
+			t.Log("Query should fail for:", expr)
+			t.Fail()
+		}
+	}
+}
+
+func TestNestedTables(t *testing.T) {
+	toml, err := ParseString(`[a.b]
+	c = 1
+
+	[[a.d]]
+	e = 2
+
+	[a.d.f]
+	g = 3
+	`)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+	if toml.GetInt("a.b.c", 0) != 1 || toml.GetInt("a.d[0].e", 0) != 2 || toml.GetInt("a.d[0].f.g", 0) != 3 {
+		t.Log("dotted table names should be nested tables")
+		t.Fail()
+	}
+}
+
+func TestQueryNaN(t *testing.T) {
+	toml, _ := ParseString("a = [ nan, 5.0 ]\n")
+	cases := []struct {
+		expr  string
+		paths []string
+	}{
+		{`a[?(@ == 5)]`, []string{`a[1]`}},
+		{`a[?(@ != 5)]`, []string{`a[0]`}},
+		{`a[?(@ <= 5)]`, []string{`a[1]`}},
+	}
+	for _, c := range cases {
+		matches, err := toml.Query(c.expr)
+		if err != nil || len(matches) != len(c.paths) || len(matches) > 0 && matches[0].Path != c.paths[0] {
+			t.Log("Query:", c.expr, "matches:", matches, "err:", err)
+			t.Fail()
+		}
+	}
+}
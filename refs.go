@@ -0,0 +1,134 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func errRefCycle(stack []string) error {
+	return errors.New(fmt.Sprint("reference cycle: ", strings.Join(stack, " -> ")))
+}
+
+//ResolveRefs replaces the references to other values of the document found in
+//its strings, as with the ResolveRefs option. Either every reference resolves,
+//or the document is left untouched.
+func (t *Toml) ResolveRefs() error {
+	if t.frozen {
+		return errFrozen
+	}
+	work := copyTable(t)
+	work.name, work.origins = t.name, t.origins
+	if err := resolveVars(work, &Options{ResolveRefs: true}); err != nil {
+		return err
+	}
+	t.dict, t.order = work.dict, work.order
+	return nil
+}
+
+//resolveVars expands the variables of every string of the document in place,
+//in the order of the keys, the first error names the path of the string and
+//where it came from.
+func resolveVars(doc *Toml, opts *Options) error {
+	r := &resolver{doc: doc, opts: opts, active: map[string]bool{}, done: map[string]bool{}}
+	if opts.ExpandEnv {
+		r.env = opts.lookupEnv()
+	}
+	for _, key := range doc.sortedKeys() {
+		if _, err := r.resolvePath(appendKey(nil, key)); err != nil {
+			path := formatPath(r.errPath)
+			if origin := doc.Origin(path); len(origin) > 0 {
+				return errors.New(fmt.Sprint(origin, ": ", path, ": ", err))
+			}
+			return errExpand(r.errPath, err)
+		}
+	}
+	return nil
+}
+
+type resolver struct {
+	doc    *Toml
+	opts   *Options
+	env    func(name string) (string, bool)
+	active map[string]bool //the paths being resolved
+	stack  []string        //the same, in order
+	done   map[string]bool
+	//the path of the string that failed, errors are only named once
+	errPath []pathSegment
+}
+
+//resolvePath resolves the value at segs, and what it contains, then returns it
+func (r *resolver) resolvePath(segs []pathSegment) (interface{}, error) {
+	path := formatPath(segs)
+	val, err := resolve(r.doc, segs)
+	if err != nil || r.done[path] {
+		return val, err
+	}
+	if r.active[path] {
+		return nil, errRefCycle(append(r.stack, path))
+	}
+	r.active[path] = true
+	r.stack = append(r.stack, path)
+	defer func() {
+		delete(r.active, path)
+		r.stack = r.stack[:len(r.stack)-1]
+	}()
+
+	switch v := val.(type) {
+	case string:
+		last := segs[len(segs)-1]
+		//strings of an array stay strings
+		val, err = expandValue(v, r.lookup, !last.isIndex)
+		if err != nil {
+			if r.errPath == nil {
+				r.errPath = segs
+			}
+			return nil, err
+		}
+		val = copyValue(val)
+		_, err = rewrite(r.doc, segs, false, func(parent interface{}, seg pathSegment) (interface{}, error) {
+			return setChild(parent, seg, val)
+		})
+		if err != nil {
+			return nil, err
+		}
+	case []string:
+		for i := range v {
+			if _, err = r.resolvePath(appendIndex(segs, i)); err != nil {
+				return nil, err
+			}
+		}
+	case *Toml:
+		for _, key := range v.sortedKeys() {
+			if _, err = r.resolvePath(appendKey(segs, key)); err != nil {
+				return nil, err
+			}
+		}
+	case []*Toml:
+		for i := range v {
+			if _, err = r.resolvePath(appendIndex(segs, i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.done[path] = true
+	return resolve(r.doc, segs)
+}
+
+//lookup finds a reference to a value of the document, and otherwise an
+//environment variable
+func (r *resolver) lookup(name string) (interface{}, bool, error) {
+	if r.opts.ResolveRefs {
+		if segs, err := parsePath(name); err == nil {
+			if _, err := resolve(r.doc, segs); err == nil {
+				val, err := r.resolvePath(segs)
+				return val, err == nil, err
+			}
+		}
+	}
+	if r.env != nil {
+		val, ok := r.env(name)
+		return val, ok, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,133 @@
+package fiptoml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveRefs(t *testing.T) {
+	input := `name = "app"
+dirs = [ "${paths.base}/a", "${paths.port}" ]
+
+[paths]
+base = "/srv/${name}"
+logs = "${paths.base}/logs"
+port = "${server.port}"
+escaped = "$${paths.base}"
+home = "${HOME:-/root}"
+
+[server]
+port = 8080
+hosts = [ "a", "b" ]
+url = "http://${server.hosts[-1]}:${server.port}/${name}"
+all = "${server.hosts}"
+copy = "${paths}"
+`
+	toml, err := ParseWith([]byte(input), &Options{ResolveRefs: true})
+	if err != nil {
+		t.Fatal("ParseWith should work. err:", err)
+	}
+	checks := map[string]string{
+		"paths.base":       "/srv/app",
+		"paths.logs":       "/srv/app/logs",
+		"paths.escaped":    "${paths.base}",
+		"paths.home":       "/root",
+		"server.url":       "http://b:8080/app",
+		"dirs[0]":          "/srv/app/a",
+		"dirs[1]":          "8080",
+		"server.copy.logs": "/srv/app/logs",
+	}
+	for path, expected := range checks {
+		if val := toml.GetString(path, ""); val != expected {
+			t.Logf("%s: expected %q, got %q", path, expected, val)
+			t.Fail()
+		}
+	}
+	if toml.GetInt("paths.port", 0) != 8080 {
+		t.Log("a whole reference should keep the type of the value")
+		t.Fail()
+	}
+	if hosts := toml.GetStringArray("server.all"); len(hosts) != 2 || hosts[1] != "b" {
+		t.Log("a whole reference to an array should copy it, got", hosts)
+		t.Fail()
+	}
+
+	toml, _ = ParseString(input)
+	if toml.GetString("paths.logs", "") != "${paths.base}/logs" {
+		t.Log("references should be left as written unless asked for")
+		t.Fail()
+	}
+	if err := toml.ResolveRefs(); err != nil || toml.GetString("paths.logs", "") != "/srv/app/logs" {
+		t.Log("ResolveRefs should resolve the document, err:", err)
+		t.Fail()
+	}
+}
+
+func TestResolveRefsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"a = \"${b}\"\nb = \"${c}\"\nc = \"${a}\"": "c: reference cycle: a -> b -> c -> a",
+		"a = \"${a}x\"":                   "a: reference cycle: a -> a",
+		"a = \"${missing}\"":              "a: ${missing} is not set",
+		"a = [1, 2]\nb = \"x${a}\"":       "b: ${a} is array of integer, only a whole value may reference it",
+		"[t]\nk = 1\n[u]\nk = \"${t}/x\"": "u.k: ${t} is table, only a whole value may reference it",
+	}
+	for input, msg := range cases {
+		_, err := ParseWith([]byte(input), &Options{ResolveRefs: true})
+		if err == nil || err.Error() != msg {
+			t.Logf("%q: expected %q, got %v", input, msg, err)
+			t.Fail()
+		}
+	}
+
+	toml, _ := ParseString("a = \"${b}\"\nb = \"${a}\"\nc = \"ok\"")
+	if err := toml.ResolveRefs(); err == nil || toml.GetString("a", "") != "${b}" {
+		t.Log("a failed ResolveRefs should leave the document untouched, err:", err)
+		t.Fail()
+	}
+}
+
+func TestResolveRefsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.toml": "base = \"/srv\"\nlogs = \"${base}/logs\"\nuser = \"${USER}\"\n",
+		"app.toml":  "include = \"${CONF}.toml\"\nbase = \"/opt\"\ncost = \"$$5\"\n",
+	})
+	opts := &Options{Includes: true, ResolveRefs: true, ExpandEnv: true,
+		LookupEnv: lookupMap(map[string]string{"CONF": "base", "USER": "admin"})}
+	toml, err := LoadWith(filepath.Join(dir, "app.toml"), opts)
+	if err != nil {
+		t.Fatal("LoadWith should work. err:", err)
+	}
+	if toml.GetString("logs", "") != "/opt/logs" || toml.GetString("user", "") != "admin" ||
+		toml.GetString("cost", "") != "$5" {
+		t.Log("references should be resolved once the files are merged, got", toml.GetString("logs", ""))
+		t.Fail()
+	}
+
+	os.WriteFile(filepath.Join(dir, "base.toml"), []byte("logs = \"${nowhere}/logs\"\n"), 0644)
+	_, err = LoadWith(filepath.Join(dir, "app.toml"), opts)
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "base.toml")+": logs: ") {
+		t.Log("the error should name the file the value came from, got", err)
+		t.Fail()
+	}
+}
+
+func ExampleToml_ResolveRefs() {
+	toml, _ := ParseString(`[paths]
+base = "/srv/app"
+logs = "${paths.base}/logs"
+workers = "${server.workers}"
+
+[server]
+workers = 4`)
+	if err := toml.ResolveRefs(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(toml.GetString("paths.logs", ""), toml.GetInt("paths.workers", 0))
+	// Output:
+	// /srv/app/logs 4
+}
@@ -0,0 +1,97 @@
+package fiptoml
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var errFrozen = errors.New("document is frozen")
+
+//Freeze makes the document and all of its tables read only: Set, Delete,
+//AppendTable and ApplyPatch fail on them from now on. A frozen document can
+//be read from any number of goroutines, and hands out copies of its arrays.
+//Clone returns a copy that is not frozen.
+func (t *Toml) Freeze() {
+	t.frozen = true
+	for _, v := range t.dict {
+		switch x := v.(type) {
+		case *Toml:
+			x.Freeze()
+		case []*Toml:
+			for _, table := range x {
+				table.Freeze()
+			}
+		}
+	}
+}
+
+func (t *Toml) Frozen() bool {
+	return t.frozen
+}
+
+//shared returns a value of the document as it is handed out: the slices of a
+//frozen document are copied, so that it cannot be changed through them. Its
+//tables are frozen themselves.
+func (t *Toml) shared(v interface{}) interface{} {
+	if !t.frozen {
+		return v
+	}
+	switch x := v.(type) {
+	case *Toml:
+		return x
+	case []*Toml:
+		return append([]*Toml(nil), x...)
+	default:
+		return copyValue(v)
+	}
+}
+
+//SafeToml shares a document between goroutines. Readers get frozen snapshots
+//which never change, writers publish new snapshots atomically:
+//
+//	conf := NewSafeToml(doc)
+//	port := conf.Load().GetInt("database.port", 5432)
+//	err := conf.Update(func(doc *Toml) error {
+//		return doc.Set("database.port", 5433)
+//	})
+type SafeToml struct {
+	mu  sync.Mutex //serializes Update
+	doc atomic.Value
+}
+
+//NewSafeToml publishes doc, which must not be modified afterwards.
+func NewSafeToml(doc *Toml) *SafeToml {
+	s := &SafeToml{}
+	s.Store(doc)
+	return s
+}
+
+//Load returns the current snapshot.
+func (s *SafeToml) Load() *Toml {
+	doc, _ := s.doc.Load().(*Toml)
+	return doc
+}
+
+//Store freezes doc and publishes it as the current snapshot.
+func (s *SafeToml) Store(doc *Toml) {
+	if doc == nil {
+		doc = NewToml()
+	}
+	doc.Freeze()
+	s.doc.Store(doc)
+}
+
+//Update calls f with a copy of the current snapshot, and publishes it unless
+//f fails. Updates are applied one at a time, and none is lost.
+func (s *SafeToml) Update(f func(doc *Toml) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc := s.Load().Clone()
+	if err := f(doc); err != nil {
+		return err
+	}
+	s.Store(doc)
+	return nil
+}
@@ -0,0 +1,109 @@
+package fiptoml
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	toml, _ := ParseString(example)
+	toml.Freeze()
+
+	owner, _ := toml.GetTableToml("owner")
+	products, _ := toml.GetTableArray("products")
+	if toml.Set("title", "x") != errFrozen || owner.Set("name", "x") != errFrozen ||
+		products[0].Delete("name") != errFrozen || toml.AppendTable("products") != nil ||
+		toml.ApplyPatch([]PatchOp{{Op: "remove", Path: "title"}}) != errFrozen {
+		t.Log("a frozen document should not be modified")
+		t.Fail()
+	}
+	if toml.GetString("title", "") != "TOML Example" || owner.GetString("name", "") != "Lance Uppercut" {
+		t.Log("a frozen document should be left untouched")
+		t.Fail()
+	}
+	if clone := toml.Clone(); clone.Frozen() || clone.Set("title", "x") != nil {
+		t.Log("a clone should not be frozen")
+		t.Fail()
+	}
+}
+
+func TestFreezeNested(t *testing.T) {
+	toml := NewToml()
+	server := NewToml()
+	server.Set("port", 80)
+	toml.Set("server", server)
+	toml.Set("hosts", []string{"a", "b"})
+	toml.Freeze()
+
+	server.Set("port", 8080)
+	if toml.GetInt("server.port", 0) != 80 {
+		t.Log("a table given to Set should be copied")
+		t.Fail()
+	}
+
+	v, _ := toml.GetValue("server")
+	nested := v.Table()
+	if nested.Set("port", 1) != errFrozen || toml.GetInt("server.port", 0) != 80 {
+		t.Log("a nested table of a frozen document should be frozen")
+		t.Fail()
+	}
+
+	toml.GetStringArray("hosts")[0] = "x"
+	v, _ = toml.GetValue("hosts")
+	v.Interface().([]string)[1] = "y"
+	toml.Walk(func(path string, v Value) error {
+		if hosts, ok := v.Interface().([]string); ok {
+			hosts[0] = "z"
+		}
+		return nil
+	})
+	if hosts := toml.GetStringArray("hosts"); hosts[0] != "a" || hosts[1] != "b" {
+		t.Log("a frozen document should hand out copies of its arrays, got", hosts)
+		t.Fail()
+	}
+
+	doc := NewToml()
+	doc.Set("server.port", 80)
+	frozen, _ := doc.GetTableToml("server")
+	frozen.Freeze()
+	if doc.Set("server.port", 1) != errFrozen {
+		t.Log("a frozen nested table should not be modified through its document")
+		t.Fail()
+	}
+}
+
+//run with -race
+func TestSafeToml(t *testing.T) {
+	doc, _ := ParseString(example)
+	conf := NewSafeToml(doc)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				conf.Update(func(doc *Toml) error {
+					return doc.Set("database.connection_max", doc.GetInt("database.connection_max", 0)+1)
+				})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := conf.Load()
+				if snapshot.GetString("database.server", "") != "192.168.1.1" ||
+					snapshot.Set("database.server", "x") == nil {
+					t.Log("snapshots should be frozen")
+					t.Fail()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if max := conf.Load().GetInt("database.connection_max", 0); max != 5400 {
+		t.Log("no update should be lost, connection_max:", max)
+		t.Fail()
+	}
+}
@@ -0,0 +1,121 @@
+package fiptoml
+
+import (
+	"bytes"
+	"io"
+)
+
+type TokenType int
+
+const (
+	TokenTableHeader TokenType = iota + 1
+	TokenArrayTableHeader
+	TokenKey
+	TokenValue
+	TokenComment
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenTableHeader:
+		return "table header"
+	case TokenArrayTableHeader:
+		return "array table header"
+	case TokenKey:
+		return "key"
+	case TokenValue:
+		return "value"
+	case TokenComment:
+		return "comment"
+	default:
+		return "unknown"
+	}
+}
+
+//Token is a piece of a document. Text is the name of a table, a key, the text
+//of a value as written or a comment including its '#'. Value holds the value
+//of a TokenValue. Lines and columns count from 1.
+type Token struct {
+	Type  TokenType
+	Text  string
+	Value Value
+	Line  int
+	Col   int
+}
+
+//Tokenizer reads a document from a stream as tokens, without building it, so
+//that a large document can be processed a table at a time. Headers are
+//followed by their comment, keys by their value and its comment.
+type Tokenizer struct {
+	s       *stmtScanner
+	pending []Token
+	err     error
+}
+
+func NewTokenizer(r io.Reader) *Tokenizer {
+	s := newStmtScanner(r)
+	s.comments = true
+	return &Tokenizer{s: s}
+}
+
+//Next returns the next token, or io.EOF at the end of the stream. Syntax
+//errors are *ParseError, and are returned again by the following calls.
+func (tk *Tokenizer) Next() (tok Token, err error) {
+	if len(tk.pending) == 0 && tk.err == nil {
+		tk.err = tk.read()
+	}
+	if len(tk.pending) == 0 {
+		return tok, tk.err
+	}
+	tok = tk.pending[0]
+	tk.pending = tk.pending[1:]
+	return
+}
+
+//read turns the next statement into tokens
+func (tk *Tokenizer) read() error {
+	st, err := tk.s.next()
+	if err != nil {
+		return err
+	}
+
+	tk.pending = tk.pending[:0]
+	switch st.kind {
+	case stmtComment:
+		tk.comment(st, 0)
+	case stmtTable, stmtTableArray:
+		skip, typ := 1, TokenTableHeader
+		if st.kind == stmtTableArray {
+			skip, typ = 2, TokenArrayTableHeader
+		}
+		name, idx, err := scanTableName(st.text[skip:], st.kind == stmtTableArray)
+		if err != nil {
+			return tk.s.fail(st, idx+skip, err)
+		}
+		tk.pending = append(tk.pending, Token{Type: typ, Text: name, Line: st.line, Col: st.col})
+		tk.comment(st, skip+idx)
+	default:
+		kv, idx, err := scanKeyValue(st.text)
+		if err != nil {
+			return tk.s.fail(st, idx, err)
+		}
+		line, col := st.pos(kv.from)
+		tk.pending = append(tk.pending,
+			Token{Type: TokenKey, Text: kv.key, Line: st.line, Col: st.col},
+			Token{TokenValue, string(st.text[kv.from:kv.to]), Value{kv.val}, line, col})
+		tk.comment(st, kv.to)
+	}
+	return nil
+}
+
+//comment adds the comment found in the statement after the offset, if any
+func (tk *Tokenizer) comment(st statement, offset int) {
+	i := bytes.IndexByte(st.text[offset:], '#')
+	if i < 0 {
+		return
+	}
+	i += offset
+	line, col := st.pos(i)
+	text := bytes.TrimRight(st.text[i:], "\r\n")
+	tk.pending = append(tk.pending, Token{Type: TokenComment, Text: string(text), Line: line, Col: col})
+}
@@ -0,0 +1,114 @@
+package fiptoml
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	tk := NewTokenizer(strings.NewReader(`# records
+title = "log" # name
+
+[[record]] # first
+id = 1
+tags = [ "a",
+  "b" ]
+`))
+	expected := []Token{
+		{TokenComment, "# records", Value{}, 1, 1},
+		{TokenKey, "title", Value{}, 2, 1},
+		{TokenValue, `"log"`, Value{"log"}, 2, 9},
+		{TokenComment, "# name", Value{}, 2, 15},
+		{TokenArrayTableHeader, "record", Value{}, 4, 1},
+		{TokenComment, "# first", Value{}, 4, 12},
+		{TokenKey, "id", Value{}, 5, 1},
+		{TokenValue, "1", Value{1}, 5, 6},
+		{TokenKey, "tags", Value{}, 6, 1},
+		{TokenValue, "[ \"a\",\n  \"b\" ]", Value{[]string{"a", "b"}}, 6, 8},
+	}
+	for i, e := range expected {
+		tok, err := tk.Next()
+		if err != nil || !reflect.DeepEqual(tok, e) {
+			t.Log("token", i, "expected", e, "got", tok, err)
+			t.Fail()
+		}
+	}
+	if _, err := tk.Next(); err != io.EOF {
+		t.Log("the end should be io.EOF, got", err)
+		t.Fail()
+	}
+}
+
+func TestTokenizerHeaderSpaces(t *testing.T) {
+	tk := NewTokenizer(strings.NewReader("[ a#b ] # x\n[[ c ]]\t# y\n"))
+	expected := []Token{
+		{TokenTableHeader, "a#b", Value{}, 1, 1},
+		{TokenComment, "# x", Value{}, 1, 9},
+		{TokenArrayTableHeader, "c", Value{}, 2, 1},
+		{TokenComment, "# y", Value{}, 2, 9},
+	}
+	for i, e := range expected {
+		tok, err := tk.Next()
+		if err != nil || !reflect.DeepEqual(tok, e) {
+			t.Log("token", i, "expected", e, "got", tok, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestTokenizerError(t *testing.T) {
+	tk := NewTokenizer(strings.NewReader("a = 1\nb = ?\n"))
+	for i := 0; i < 2; i++ {
+		if tok, err := tk.Next(); err != nil {
+			t.Log("the first statement should be read, got", tok, err)
+			t.Fail()
+		}
+	}
+	for i := 0; i < 2; i++ {
+		_, err := tk.Next()
+		if pe, ok := err.(*ParseError); !ok || pe.Line != 2 || pe.Col != 5 {
+			t.Log("the error should be returned again with its position, got", err)
+			t.Fail()
+		}
+	}
+}
+
+//records are processed one at a time, the document is never built
+func ExampleTokenizer() {
+	tk := NewTokenizer(strings.NewReader(`[[record]]
+id = 1
+size = 120
+
+[[record]]
+id = 2
+size = 80
+`))
+	records, total := 0, 0
+	key := ""
+	for {
+		tok, err := tk.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		switch tok.Type {
+		case TokenArrayTableHeader:
+			records++
+		case TokenKey:
+			key = tok.Text
+		case TokenValue:
+			if key == "size" {
+				total += tok.Value.Int()
+			}
+		}
+	}
+	fmt.Println(records, "records,", total, "bytes")
+	// Output:
+	// 2 records, 200 bytes
+}
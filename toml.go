@@ -2,12 +2,11 @@ package fiptoml
 
 import (
 	"errors"
-	"strings"
 	"time"
 	"fmt"
-	"bufio"
 	"strconv"
 	"reflect"
+	"sort"
 )
 
 var (
@@ -17,237 +16,215 @@ var (
 )
 
 type Toml struct {
-	dict map[string]interface{}
+	dict  map[string]interface{}
+	order []string //keys in the order they were added, may hold removed keys
+
+	//of a root document only: the file it was loaded from, and where values
+	//merged from other documents came from
+	name    string
+	origins map[string]string
+
+	frozen bool
 }
 
 func NewToml() *Toml {
-	return &Toml{make(map[string]interface{})}
+	return &Toml{dict: make(map[string]interface{})}
 }
 
-func (t *Toml) GetStringEx(key string) (val string, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
+func (t *Toml) sortedKeys() []string {
+	keys := make([]string, 0, len(t.dict))
+	for key := range t.dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-	switch v := doc.dict[fKey].(type) {
-	case string:
-		val = v
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+//set stores the value of key, remembering the order of new keys. The keys
+//removed are dropped from the order once they make up half of it.
+func (t *Toml) set(key string, v interface{}) {
+	if _, ok := t.dict[key]; !ok {
+		if len(t.order) > 2*len(t.dict)+8 {
+			t.order = t.orderedKeys()
+		}
+		t.order = append(t.order, key)
 	}
-	return
+	t.dict[key] = v
 }
 
-func (t *Toml) GetString(key string, dflt string) string {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		fmt.Println("err:", err)
-		return dflt
+//orderedKeys returns the keys in the order they were added, a key removed
+//then added again taking its last place. Keys stored without set follow,
+//sorted.
+func (t *Toml) orderedKeys() []string {
+	keys := make([]string, len(t.dict))
+	seen := make(map[string]bool, len(t.dict))
+	n := len(keys)
+	for i := len(t.order) - 1; i >= 0; i-- {
+		key := t.order[i]
+		if _, ok := t.dict[key]; ok && !seen[key] {
+			seen[key] = true
+			n--
+			keys[n] = key
+		}
 	}
+	if n == 0 {
+		return keys
+	}
+	for _, key := range t.sortedKeys() {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys[n:]
+}
 
-	switch v := doc.dict[fKey].(type) {
-	case string:
-		return v
-	default:
-		return dflt
+//Keys returns the keys of the table in sorted order.
+func (t *Toml) Keys() []string {
+	return t.sortedKeys()
+}
+
+//Len returns the number of keys of the table.
+func (t *Toml) Len() int {
+	return len(t.dict)
+}
+
+func (t *Toml) Has(path string) bool {
+	_, err := t.GetPath(path)
+	return err == nil
+}
+
+//Kind returns the kind of the value at path, Invalid if there is none.
+func (t *Toml) Kind(path string) Kind {
+	v, err := t.GetPath(path)
+	if err != nil {
+		return Invalid
 	}
+	return kindOf(v)
 }
 
-func (t *Toml) GetBoolEx(key string) (val bool, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case bool:
-		val = v
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+//GetValue returns the value at path.
+func (t *Toml) GetValue(key string) (val Value, err error) {
+	raw, err := t.GetPath(key)
+	if err == nil {
+		val = Value{raw}
 	}
 	return
 }
 
-func (t *Toml) GetBool(key string, dflt bool) bool {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
+func (t *Toml) GetStringEx(key string) (val string, err error) {
+	v, err := t.GetValue(key)
 	if err != nil {
-		return dflt
+		return
 	}
+	return v.StringEx()
+}
 
-	switch v := doc.dict[fKey].(type) {
-	case bool:
-		return v
-	default:
-		return dflt
+func (t *Toml) GetString(key string, dflt string) string {
+	if val, err := t.GetStringEx(key); err == nil {
+		return val
 	}
+	return dflt
 }
 
-func (t *Toml) GetIntEx(key string) (val int, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case int:
-		val = v
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+func (t *Toml) GetBoolEx(key string) (val bool, err error) {
+	v, err := t.GetValue(key)
+	if err != nil {
+		return
 	}
-	return
+	return v.BoolEx()
 }
 
-func (t *Toml) GetInt(key string, dflt int) int {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
+func (t *Toml) GetBool(key string, dflt bool) bool {
+	if val, err := t.GetBoolEx(key); err == nil {
+		return val
+	}
+	return dflt
+}
+
+func (t *Toml) GetIntEx(key string) (val int, err error) {
+	v, err := t.GetValue(key)
 	if err != nil {
-		return dflt
+		return
 	}
-	switch v := doc.dict[fKey].(type) {
-	case int:
-		return v
-	default:
-		return dflt
+	return v.IntEx()
+}
+
+func (t *Toml) GetInt(key string, dflt int) int {
+	if val, err := t.GetIntEx(key); err == nil {
+		return val
 	}
+	return dflt
 }
 
 func (t *Toml) GetFloatEx(key string) (val float64, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case float64:
-		val = v
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+	v, err := t.GetValue(key)
+	if err != nil {
+		return
 	}
-	return
+	return v.FloatEx()
 }
 
 func (t *Toml) GetFloat(key string, dflt float64) float64 {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return dflt
-	}
-	switch v := doc.dict[fKey].(type) {
-	case float64:
-		return v
-	default:
-		return dflt
+	if val, err := t.GetFloatEx(key); err == nil {
+		return val
 	}
+	return dflt
 }
 
 func (t *Toml) GetDatetimeEx(key string) (val time.Time, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case time.Time:
-		val = v
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+	v, err := t.GetValue(key)
+	if err != nil {
+		return
 	}
-	return
+	return v.TimeEx()
 }
 
 func (t *Toml) GetDatetime(key string, dflt time.Time) time.Time {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return dflt
-	}
-	switch v := doc.dict[fKey].(type) {
-	case time.Time:
-		return v
-	default:
-		return dflt
+	if val, err := t.GetDatetimeEx(key); err == nil {
+		return val
 	}
+	return dflt
 }
 
 func (t *Toml) GetArrayEx(key string) (array interface{}, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch arr := doc.dict[fKey].(type) {
-	case []string:
-		array = arr
-	case []bool:
-		array = arr
-	case []int:
-		array = arr
-	case []float64:
-		array = arr
-	case []time.Time:
-		array = arr
-	case nil:
-		err = errValueNotFound
-	default:
-		err = errTypeMismatch
+	v, err := t.GetValue(key)
+	if err != nil {
+		return
+	}
+	if err = v.check(Array); err == nil {
+		array = v.raw
 	}
 	return
 }
 
 func (t *Toml) GetStringArray(key string) []string {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return nil
-	}
-
-	switch arr := doc.dict[fKey].(type) {
-	case []string:
-		return arr
-	default:
-		return nil
-	}
+	raw, _ := t.GetPath(key)
+	arr, _ := raw.([]string)
+	return arr
 }
 
 func (t *Toml) GetBoolArray(key string) []bool {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return nil
-	}
-
-	switch arr := doc.dict[fKey].(type) {
-	case []bool:
-		return arr
-	default:
-		return nil
-	}
+	raw, _ := t.GetPath(key)
+	arr, _ := raw.([]bool)
+	return arr
 }
 
 func (t *Toml) GetIntArray(key string) []int {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return nil
-	}
-
-	switch arr := doc.dict[fKey].(type) {
-	case []int:
-		return arr
-	default:
-		return nil
-	}
+	raw, _ := t.GetPath(key)
+	arr, _ := raw.([]int)
+	return arr
 }
 
 func (t *Toml) GetFloatArray(key string) []float64 {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		return nil
-	}
-
-	switch arr := doc.dict[fKey].(type) {
-	case []float64:
-		return arr
-	default:
-		return nil
-	}
+	raw, _ := t.GetPath(key)
+	arr, _ := raw.([]float64)
+	return arr
 }
 
 func (t *Toml) GetDatetimeArray(key string) []time.Time {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	if err != nil {
-		fmt.Println("key,err:",key,err)
-		return nil
-	}
-
-	switch arr := doc.dict[fKey].(type) {
-	case []time.Time:
-		return arr
-	default:
-		return nil
-	}
+	raw, _ := t.GetPath(key)
+	arr, _ := raw.([]time.Time)
+	return arr
 }
 
 /*func (t *toml) GetArray(key string,dflt interface{}) interface {} {
@@ -274,60 +251,23 @@ func (t *Toml) GetDatetimeArray(key string) []time.Time {
 }*/
 
 func (t *Toml) GetTableToml(key string) (table *Toml, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case *Toml:
-		table = v
-	case nil:
-		//err = errValueNotFound
-		table = nil
-	default:
-		err = errTypeMismatch
+	v, err := t.GetValue(key)
+	if err == errValueNotFound {
+		return nil, nil
+	} else if err != nil {
+		return
 	}
-	return
+	return v.TableEx()
 }
 
 func (t *Toml) GetTableArray(key string) (array []*Toml, err error) {
-	fKey, doc, err := getFinalKeyAndTable(key, t)
-	switch v := doc.dict[fKey].(type) {
-	case []*Toml:
-		array = v
-	case nil:
-		err = errValueNotFound
-		//table = nil
-	default:
-		err = errTypeMismatch
-	}
-	return
-}
-
-func getFinalKeyAndTable(key string, doc *Toml) (finalKey string, finalToml *Toml, err error) {
-	if len(key) == 0 {
-		err = errNoKey
-		return
-	}
-
-	keys := strings.SplitN(key, ".", 2)
-	//keys[0] is always there
-	if len(keys[0]) == 0 {
-		err = errNoKey
+	v, err := t.GetValue(key)
+	if err != nil {
 		return
 	}
-	if len(keys) == 1 {
-		finalKey, finalToml = key, doc
-	} else {
-		if len(keys[1]) == 0 {
-			err = errNoKey
-			return
-		}
-		switch v := doc.dict[keys[0]].(type) {
-		case *Toml:
-			finalKey, finalToml, err = getFinalKeyAndTable(keys[1], v)
-		default:
-			err = errValueNotFound
-		}
+	if err = v.check(TableArray); err == nil {
+		array = v.raw.([]*Toml)
 	}
-
 	return
 }
 
@@ -355,8 +295,6 @@ func (t toml) getStruct(key string, st interface {}) ( err error) {
 		for key := range doc.dict {
 			v.FieldByName(key) = doc[key] //by type recursive
 		}
-	case nil:
-		err = errValueNotFound
 	default:
 		err = errTypeMismatch
 	}
@@ -364,31 +302,16 @@ func (t toml) getStruct(key string, st interface {}) ( err error) {
 }
 */
 
-func (t *Toml) SetValue(key string, v interface {}) {
-	t.dict[key] = v
+//SetValue is Set, kept for compatibility: key is a path, and values Set does
+//not support are rejected with an error instead of being stored.
+func (t *Toml) SetValue(key string, v interface {}) error {
+	return t.Set(key, v)
 }
 /*
 func (t *Toml) SetTable(key string, v *Toml) {
 
 }*/
 
-func (t *Toml) WriteTo(writer *bufio.Writer) {
-	for key := range t.dict {
-		switch val := t.dict[key].(type) {
-		case []*Toml:
-			fmt.Fprint(writer,"[[",key,"]]\n")
-			for _,st := range val {
-				st.WriteTo(writer)
-			}
-		case *Toml:
-			fmt.Fprint(writer, "[",key,"]\n")
-			val.WriteTo(writer)
-		default:
-			fmt.Fprintln(writer,key,"=",wrapVal(val))
-		}
-	}
-}
-
 func wrapVal(val interface {}) string {
 	switch v := val.(type) {
 	case string:
@@ -447,7 +370,6 @@ func wrapVal(val interface {}) string {
 				s += ","
 			}
 			s += fmt.Sprint("\"",v[i],"\"")
-			fmt.Println("wrap:",i,l,s)
 		}
 		s += "]"
 		return s
@@ -0,0 +1,192 @@
+package fiptoml
+
+import (
+	"reflect"
+	"time"
+)
+
+//Kind is the TOML type of a value.
+type Kind int
+
+const (
+	Invalid Kind = iota
+	String
+	Integer
+	Float
+	Bool
+	Datetime
+	Array
+	Table
+	TableArray
+)
+
+var kindNames = []string{
+	Invalid:    "invalid",
+	String:     "string",
+	Integer:    "integer",
+	Float:      "float",
+	Bool:       "bool",
+	Datetime:   "datetime",
+	Array:      "array",
+	Table:      "table",
+	TableArray: "array of tables",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[Invalid]
+	}
+	return kindNames[k]
+}
+
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case string:
+		return String
+	case int:
+		return Integer
+	case float64:
+		return Float
+	case bool:
+		return Bool
+	case time.Time:
+		return Datetime
+	case []string, []int, []float64, []bool, []time.Time, []interface{}:
+		return Array
+	case *Toml:
+		return Table
+	case []*Toml:
+		return TableArray
+	default:
+		return Invalid
+	}
+}
+
+//Value is a value of a document. The zero Value is Invalid.
+type Value struct {
+	raw interface{}
+}
+
+func (v Value) Kind() Kind {
+	return kindOf(v.raw)
+}
+
+//Interface returns the value as it is held by the document.
+func (v Value) Interface() interface{} {
+	return v.raw
+}
+
+func (v Value) check(kind Kind) error {
+	switch v.Kind() {
+	case kind:
+		return nil
+	case Invalid:
+		return errValueNotFound
+	default:
+		return errTypeMismatch
+	}
+}
+
+func (v Value) StringEx() (val string, err error) {
+	if err = v.check(String); err == nil {
+		val = v.raw.(string)
+	}
+	return
+}
+
+//String returns the string, or the TOML representation of any other kind so
+//that a Value prints sensibly.
+func (v Value) String() string {
+	switch val := v.raw.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		return wrapVal(val)
+	}
+}
+
+func (v Value) IntEx() (val int, err error) {
+	if err = v.check(Integer); err == nil {
+		val = v.raw.(int)
+	}
+	return
+}
+
+func (v Value) Int() int {
+	val, _ := v.IntEx()
+	return val
+}
+
+func (v Value) FloatEx() (val float64, err error) {
+	if err = v.check(Float); err == nil {
+		val = v.raw.(float64)
+	}
+	return
+}
+
+func (v Value) Float() float64 {
+	val, _ := v.FloatEx()
+	return val
+}
+
+func (v Value) BoolEx() (val bool, err error) {
+	if err = v.check(Bool); err == nil {
+		val = v.raw.(bool)
+	}
+	return
+}
+
+func (v Value) Bool() bool {
+	val, _ := v.BoolEx()
+	return val
+}
+
+func (v Value) TimeEx() (val time.Time, err error) {
+	if err = v.check(Datetime); err == nil {
+		val = v.raw.(time.Time)
+	}
+	return
+}
+
+func (v Value) Time() time.Time {
+	val, _ := v.TimeEx()
+	return val
+}
+
+//ArrayEx returns the elements of an array or of an array of tables.
+func (v Value) ArrayEx() (vals []Value, err error) {
+	if v.Kind() == TableArray {
+		for _, table := range v.raw.([]*Toml) {
+			vals = append(vals, Value{table})
+		}
+		return
+	}
+	if err = v.check(Array); err != nil {
+		return
+	}
+	rv := reflect.ValueOf(v.raw)
+	vals = make([]Value, rv.Len())
+	for i := range vals {
+		vals[i] = Value{rv.Index(i).Interface()}
+	}
+	return
+}
+
+func (v Value) Array() []Value {
+	vals, _ := v.ArrayEx()
+	return vals
+}
+
+func (v Value) TableEx() (val *Toml, err error) {
+	if err = v.check(Table); err == nil {
+		val = v.raw.(*Toml)
+	}
+	return
+}
+
+func (v Value) Table() *Toml {
+	val, _ := v.TableEx()
+	return val
+}
@@ -0,0 +1,60 @@
+package fiptoml
+
+import (
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	toml, err := ParseString(example)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	v, err := toml.GetValue("database")
+	if err != nil || v.Kind() != Table || v.Table().GetString("server", "") != "192.168.1.1" {
+		t.Log("GetValue database:", v, "err:", err)
+		t.Fail()
+	}
+	if _, err := v.IntEx(); err != errTypeMismatch {
+		t.Log("IntEx of a table should fail. err:", err)
+		t.Fail()
+	}
+
+	v, _ = toml.GetValue("database.ports")
+	ports := v.Array()
+	if len(ports) != 3 || ports[2].Int() != 8002 || v.String() != "[8001,8001,8002]" {
+		t.Log("Array of ports:", ports, "String:", v.String())
+		t.Fail()
+	}
+
+	v, _ = toml.GetValue("products")
+	products := v.Array()
+	if len(products) != 3 || products[2].Table().GetString("name", "") != "Nail" {
+		t.Log("Array of products:", products)
+		t.Fail()
+	}
+
+	v, _ = toml.GetValue("owner.dob")
+	if v.Time().Year() != 1979 || v.Bool() || v.Float() != 0 || v.Table() != nil {
+		t.Log("Time of dob:", v.Time())
+		t.Fail()
+	}
+
+	v, _ = toml.GetValue("database.enabled")
+	if b, err := v.BoolEx(); !b || err != nil {
+		t.Log("BoolEx of enabled:", b, "err:", err)
+		t.Fail()
+	}
+
+	var zero Value
+	if zero.Kind() != Invalid || zero.String() != "" || zero.Interface() != nil {
+		t.Log("zero Value should be invalid")
+		t.Fail()
+	}
+	if _, err := zero.StringEx(); err != errValueNotFound {
+		t.Log("StringEx of zero Value. err:", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,38 @@
+package fiptoml
+
+import (
+	"errors"
+)
+
+//SkipTable is returned by a walk function to skip the children of the table or
+//array of tables it was called for.
+var SkipTable = errors.New("skip this table")
+
+//Walk calls fn for every value of the document, depth first, keys of a table
+//in sorted order. Tables and arrays of tables are visited before their
+//children, the elements of an array of tables as path[i]. Elements of other
+//arrays are not visited separately. Walk stops at the first error returned by
+//fn other than SkipTable, and returns it.
+func (t *Toml) Walk(fn func(path string, v Value) error) error {
+	return walk(queryNode{val: t}, func(path string, v Value) error {
+		return fn(path, Value{t.shared(v.raw)})
+	})
+}
+
+func walk(n queryNode, fn func(path string, v Value) error) error {
+	for _, c := range children(n) {
+		err := fn(formatPath(c.segs), Value{c.val})
+		if err == SkipTable {
+			continue
+		} else if err != nil {
+			return err
+		}
+		switch c.val.(type) {
+		case *Toml, []*Toml:
+			if err := walk(c, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
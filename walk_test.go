@@ -0,0 +1,92 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ExampleToml_Walk() {
+	toml, err := ParseString(`title = "Walk"
+
+[owner]
+name = "Tom"
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+`)
+	if err != nil {
+		fmt.Println("ParseString should work")
+		return
+	}
+	toml.Walk(func(path string, v Value) error {
+		fmt.Println(path, v.Kind())
+		if path == "owner" {
+			return SkipTable
+		}
+		return nil
+	})
+	// Output:
+	// owner table
+	// products array of tables
+	// products[0] table
+	// products[0].name string
+	// products[1] table
+	// products[1].name string
+	// title string
+}
+
+func TestWalkError(t *testing.T) {
+	toml, _ := ParseString(example)
+	stop := errors.New("stop")
+	count := 0
+	err := toml.Walk(func(path string, v Value) error {
+		count++
+		if path == "database.enabled" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 3 {
+		t.Log("Walk should stop at the first error. err:", err, "count:", count)
+		t.Fail()
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	toml, err := ParseString(example)
+	if err != nil {
+		t.Log("ParseString should work. err:", err)
+		t.Fail()
+		return
+	}
+
+	keys := []string{"database", "files", "owner", "products", "title"}
+	if !reflect.DeepEqual(toml.Keys(), keys) || toml.Len() != len(keys) {
+		t.Log("Keys:", toml.Keys(), "Len:", toml.Len())
+		t.Fail()
+	}
+
+	kinds := map[string]Kind{
+		"title":                   String,
+		"database.connection_max": Integer,
+		"database.enabled":        Bool,
+		"owner.dob":               Datetime,
+		"files":                   Array,
+		"files[0]":                String,
+		"owner":                   Table,
+		"products":                TableArray,
+		"products[1]":             Table,
+		"missing":                 Invalid,
+	}
+	for path, kind := range kinds {
+		if toml.Kind(path) != kind || toml.Has(path) != (kind != Invalid) {
+			t.Log("Kind of", path, "is", toml.Kind(path), "want", kind)
+			t.Fail()
+		}
+	}
+}
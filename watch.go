@@ -0,0 +1,228 @@
+package fiptoml
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+type WatchOptions struct {
+	//between two checks of the files, a second by default
+	Interval time.Duration
+	//a new document is published only if Validate accepts it
+	Validate func(doc *Toml) error
+	//called with the errors of the checks made by Start
+	OnError func(err error)
+	//how the files are merged, in order, when watching several
+	Merge *MergeOptions
+	//where the files are read from, the operating system by default
+	FS fs.FS
+}
+
+//Watcher polls configuration files and publishes a new document whenever they
+//change, parse cleanly and are accepted by WatchOptions.Validate. Otherwise
+//the current document stays in place.
+type Watcher struct {
+	opts  WatchOptions
+	paths []string
+	files files
+	conf  *SafeToml
+
+	mu     sync.Mutex //serializes the checks, guards the fields below
+	stamps []fileStamp
+	subs   []func(old, new *Toml, changes []Change)
+	stop   chan struct{}
+	//documents published, not yet passed to the subscribers
+	pending []publication
+	//a goroutine is passing them, in order
+	notifying bool
+}
+
+type publication struct {
+	old, new *Toml
+	changes  []Change
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+//NewWatcher loads the files, merged in order, and fails if they do not parse
+//or validate.
+func NewWatcher(opts *WatchOptions, paths ...string) (w *Watcher, err error) {
+	w = &Watcher{paths: paths}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = time.Second
+	}
+	w.files = filesOf(w.opts.FS)
+
+	w.stamps, err = w.stat()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.conf = NewSafeToml(doc)
+	return
+}
+
+//Current returns the last document published, frozen.
+func (w *Watcher) Current() *Toml {
+	return w.conf.Load()
+}
+
+//Subscribe registers fn to be called with the old and the new document and the
+//changes between them whenever a new document is published.
+func (w *Watcher) Subscribe(fn func(old, new *Toml, changes []Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+//Reload checks the files now, and publishes a new document if they changed.
+//Subscribers are called once the document is published, outside of the
+//check, so they may call the methods of the Watcher. They are called with one
+//document at a time, in the order the documents are published: a Reload made
+//while they are being called returns once its document is published, the
+//goroutine calling them passing it on in turn.
+func (w *Watcher) Reload() error {
+	return w.reload(nil)
+}
+
+//reload checks the files, unless stop is closed, then passes the documents
+//published to the subscribers if no other goroutine is doing so
+func (w *Watcher) reload(stop chan struct{}) (err error) {
+	w.mu.Lock()
+	select {
+	case <-stop:
+		w.mu.Unlock()
+		return
+	default:
+	}
+	old, doc, changes, err := w.check()
+	if err == nil && len(changes) > 0 {
+		w.pending = append(w.pending, publication{old, doc, changes})
+	}
+	if w.notifying {
+		w.mu.Unlock()
+		return
+	}
+
+	w.notifying = true
+	for len(w.pending) > 0 {
+		p, subs := w.pending[0], w.subs
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+		for _, fn := range subs {
+			fn(p.old, p.new, p.changes)
+		}
+		w.mu.Lock()
+	}
+	w.notifying = false
+	w.mu.Unlock()
+	return
+}
+
+//check publishes a new document if the files changed
+func (w *Watcher) check() (old, doc *Toml, changes []Change, err error) {
+	stamps, err := w.stat()
+	if err != nil || stampsEqual(stamps, w.stamps) {
+		return
+	}
+	if doc, err = w.load(); err != nil {
+		return
+	}
+	//unchanged files are not checked again, until they change
+	w.stamps = stamps
+
+	old = w.conf.Load()
+	if changes = Diff(old, doc); len(changes) > 0 {
+		w.conf.Store(doc)
+	}
+	return
+}
+
+//Start checks the files every WatchOptions.Interval in a new goroutine.
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := w.reload(stop); err != nil && w.opts.OnError != nil {
+					w.opts.OnError(err)
+				}
+			}
+		}
+	}(w.stop)
+}
+
+//Stop stops the checks started by Start, and waits for the one under way: no
+//check is made once it returns. Subscribers may still be called with the
+//documents published before, and may call Stop themselves.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+func (w *Watcher) stat() ([]fileStamp, error) {
+	stamps := make([]fileStamp, len(w.paths))
+	for i, path := range w.paths {
+		info, err := w.files.stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps[i] = fileStamp{info.ModTime(), info.Size()}
+	}
+	return stamps, nil
+}
+
+func (w *Watcher) load() (doc *Toml, err error) {
+	for _, path := range w.paths {
+		layer, err := loadFile(w.files, path, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			doc = layer
+		} else {
+			doc = Merge(doc, layer, w.opts.Merge)
+		}
+	}
+	if doc == nil {
+		doc = NewToml()
+	}
+	if w.opts.Validate != nil {
+		err = w.opts.Validate(doc)
+	}
+	return
+}
+
+func stampsEqual(a, b []fileStamp) bool {
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
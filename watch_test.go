@@ -0,0 +1,204 @@
+package fiptoml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//writes the file and moves its modification time forward, so that the change
+//is seen whatever the resolution of the file system
+func writeWatched(t *testing.T, path, content string, at time.Time) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	base, local := filepath.Join(dir, "base.toml"), filepath.Join(dir, "local.toml")
+	now := time.Now()
+	writeWatched(t, base, "port = 8000\nhost = \"localhost\"\n", now)
+	writeWatched(t, local, "port = 8080\n", now)
+
+	errInvalid := errors.New("port out of range")
+	w, err := NewWatcher(&WatchOptions{Validate: func(doc *Toml) error {
+		if doc.GetInt("port", 0) >= 10000 {
+			return errInvalid
+		}
+		return nil
+	}}, base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Current().GetInt("port", 0) != 8080 || w.Current().Origin("port") != local {
+		t.Log("the files should be merged in order")
+		t.Fail()
+	}
+
+	var received []Change
+	w.Subscribe(func(old, new *Toml, changes []Change) {
+		if old.GetInt("port", 0) != 8080 || new.GetInt("port", 0) != 8081 {
+			t.Log("subscribers should get the old and the new document")
+			t.Fail()
+		}
+		received = changes
+	})
+
+	if err = w.Reload(); err != nil || received != nil {
+		t.Log("unchanged files should not be reloaded:", err)
+		t.Fail()
+	}
+
+	writeWatched(t, local, "port = 8081\n", now.Add(time.Second))
+	if err = w.Reload(); err != nil || len(received) != 1 || received[0].Path != "port" {
+		t.Log("a change should be published:", err, received)
+		t.Fail()
+	}
+
+	received = nil
+	writeWatched(t, local, "port = \n", now.Add(2*time.Second))
+	if err = w.Reload(); err == nil || received != nil || w.Current().GetInt("port", 0) != 8081 {
+		t.Log("a file which does not parse should keep the current document")
+		t.Fail()
+	}
+	writeWatched(t, local, "port = 10000\n", now.Add(3*time.Second))
+	if err = w.Reload(); err != errInvalid || received != nil || w.Current().GetInt("port", 0) != 8081 {
+		t.Log("a document which does not validate should keep the current document:", err)
+		t.Fail()
+	}
+	if !w.Current().Frozen() {
+		t.Log("the current document should be frozen")
+		t.Fail()
+	}
+}
+
+func TestWatcherStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	now := time.Now()
+	writeWatched(t, path, "level = \"info\"\n", now)
+
+	w, err := NewWatcher(&WatchOptions{Interval: 10 * time.Millisecond}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := make(chan *Toml, 1)
+	w.Subscribe(func(old, new *Toml, changes []Change) {
+		published <- new
+	})
+	w.Start()
+	defer w.Stop()
+
+	writeWatched(t, path, "level = \"debug\"\n", now.Add(time.Second))
+	select {
+	case doc := <-published:
+		if doc.GetString("level", "") != "debug" {
+			t.Log("the new document should be published")
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("the change should be noticed")
+		t.Fail()
+	}
+}
+
+func TestWatcherReentrantSubscribers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	now := time.Now()
+	writeWatched(t, path, "level = \"info\"\n", now)
+
+	w, err := NewWatcher(&WatchOptions{Interval: 10 * time.Millisecond}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	w.Subscribe(func(old, new *Toml, changes []Change) {
+		//a subscriber may reload, subscribe and stop
+		if err := w.Reload(); err != nil {
+			t.Log("Reload from a subscriber should work. err:", err)
+			t.Fail()
+		}
+		w.Subscribe(func(old, new *Toml, changes []Change) {})
+		w.Stop()
+		close(stopped)
+	})
+	w.Start()
+
+	writeWatched(t, path, "level = \"debug\"\n", now.Add(time.Second))
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a subscriber calling the watcher should not deadlock")
+	}
+	if w.Current().GetString("level", "") != "debug" {
+		t.Log("the new document should be published")
+		t.Fail()
+	}
+}
+
+func TestWatcherOrderedSubscribers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	now := time.Now()
+	writeWatched(t, path, "n = 0\n", now)
+
+	w, err := NewWatcher(&WatchOptions{Interval: time.Millisecond}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const last = 20
+	seen, calls := 0, 0
+	stopped := make(chan struct{})
+	w.Subscribe(func(old, new *Toml, changes []Change) {
+		calls++
+		if old.GetInt("n", -1) != seen || new.GetInt("n", -1) <= seen {
+			t.Log("subscribers should get the documents in order, got", old.GetInt("n", -1), "->", new.GetInt("n", -1), "after", seen)
+			t.Fail()
+		}
+		seen = new.GetInt("n", -1)
+		if seen == last {
+			//the poller or a Reload may be the caller
+			w.Stop()
+			close(stopped)
+		}
+	})
+	w.Start()
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 1; i <= last; i++ {
+			//renamed, so that the poller never reads half a file
+			at, tmp := now.Add(time.Duration(i)*time.Second), path+".tmp"
+			if os.WriteFile(tmp, []byte(fmt.Sprint("n = ", i, "\n")), 0644) != nil ||
+				os.Chtimes(tmp, at, at) != nil || os.Rename(tmp, path) != nil {
+				t.Log("the file should be written")
+				t.Fail()
+				return
+			}
+			w.Reload()
+		}
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a subscriber stopping the watcher should not deadlock")
+	}
+	<-written
+	if calls == 0 {
+		t.Log("subscribers should be called")
+		t.Fail()
+	}
+}
+
+func TestWatcherMissingFile(t *testing.T) {
+	if _, err := NewWatcher(nil, filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Log("a missing file should fail")
+		t.Fail()
+	}
+}
@@ -0,0 +1,131 @@
+package fiptoml
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var errTooManyLinks = errors.New("too many symbolic links")
+
+type WriteOptions struct {
+	//Backup keeps the file replaced as path.bak
+	Backup bool
+	//the permissions of a new file, 0644 by default, a file replaced keeps its
+	//own
+	Perm fs.FileMode
+	//the format of the document, see Encoder
+	Format *EncodeOptions
+}
+
+func Write(doc *Toml, path string) (err error) {
+	return WriteWith(doc, path, nil)
+}
+
+//WriteWith writes the document to a temporary file next to path, syncs it and
+//renames it over path, so that path holds either the old document or the new
+//one whatever happens. A file replaced keeps its mode, and its owner where
+//permitted. When path is a symbolic link, the file it points to is replaced,
+//or created if missing. Every error met is returned, a nil document being one,
+//and the temporary file removed.
+func WriteWith(doc *Toml, path string, opts *WriteOptions) (err error) {
+	if doc == nil {
+		return errNilDocument
+	}
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	if path, err = followLinks(path); err != nil {
+		return
+	}
+
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		perm = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	renamed := false
+	defer func() {
+		if err != nil && !renamed {
+			err = errors.Join(err, os.Remove(tmp.Name()))
+		}
+	}()
+
+	if err = writeTemp(tmp, doc, opts.Format, perm, info); err != nil {
+		return
+	}
+	if info != nil && opts.Backup {
+		if err = backup(path); err != nil {
+			return
+		}
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return
+	}
+	renamed = true
+	return syncDir(filepath.Dir(path))
+}
+
+//followLinks returns the file path points to, following symbolic links up to
+//the first name missing, if any
+func followLinks(path string) (target string, err error) {
+	target, err = filepath.EvalSymlinks(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	//a link to a missing file, or a missing file
+	for i := 0; i < 255; i++ {
+		var info fs.FileInfo
+		info, err = os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) || err == nil && info.Mode()&fs.ModeSymlink == 0 {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+		if target, err = os.Readlink(path); err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return "", errTooManyLinks
+}
+
+//writeTemp writes the document, gives the file the mode and the owner of the
+//one replaced, if any, syncs it and closes it
+func writeTemp(file *os.File, doc *Toml, format *EncodeOptions, perm fs.FileMode, replaced fs.FileInfo) (err error) {
+	err = NewEncoderWith(file, format).Encode(doc)
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil && replaced != nil {
+		err = chown(file, replaced)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	return errors.Join(err, file.Close())
+}
+
+//backup links the file at path as path.bak, so that it stays in place until
+//renamed over
+func backup(path string) error {
+	bak := path + ".bak"
+	if err := os.Remove(bak); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Link(path, bak)
+}
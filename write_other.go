@@ -0,0 +1,19 @@
+//go:build !unix
+
+package fiptoml
+
+import (
+	"io/fs"
+	"os"
+)
+
+//owners are only preserved on unix
+func chown(file *os.File, replaced fs.FileInfo) error {
+	return nil
+}
+
+//directories cannot be synced everywhere, renames are as durable as the
+//system makes them
+func syncDir(dir string) error {
+	return nil
+}
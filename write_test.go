@@ -0,0 +1,104 @@
+package fiptoml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteWith(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.toml")
+	toml := NewToml()
+	toml.Set("port", 8080)
+
+	if err := WriteWith(toml, path, &WriteOptions{Perm: 0600}); err != nil {
+		t.Fatal("WriteWith should work. err:", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Log("a new file should get Perm, got", info.Mode().Perm())
+		t.Fail()
+	}
+
+	os.Chmod(path, 0640)
+	toml.Set("port", 9090)
+	if err := WriteWith(toml, path, &WriteOptions{Backup: true}); err != nil {
+		t.Fatal("WriteWith should work. err:", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Log("a file replaced should keep its mode, got", info.Mode().Perm())
+		t.Fail()
+	}
+	written, err := Load(path)
+	if err != nil || written.GetInt("port", 0) != 9090 {
+		t.Log("the new document should be written, err:", err)
+		t.Fail()
+	}
+	bak, err := Load(path + ".bak")
+	if err != nil || bak.GetInt("port", 0) != 8080 {
+		t.Log("the old document should be kept as .bak, err:", err)
+		t.Fail()
+	}
+
+	link := filepath.Join(dir, "link.toml")
+	os.Symlink(path, link)
+	toml.Set("port", 1)
+	if err := Write(toml, link); err != nil {
+		t.Fatal("Write should work. err:", err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Log("a link should not be replaced")
+		t.Fail()
+	}
+	if written, _ = Load(path); written.GetInt("port", 0) != 1 {
+		t.Log("the file linked should be written")
+		t.Fail()
+	}
+
+	dangling := filepath.Join(dir, "dangling.toml")
+	os.Symlink("new.toml", dangling)
+	if err := Write(toml, dangling); err != nil {
+		t.Fatal("Write should work. err:", err)
+	}
+	if info, _ := os.Lstat(dangling); info.Mode()&os.ModeSymlink == 0 {
+		t.Log("a dangling link should not be replaced")
+		t.Fail()
+	}
+	if written, _ = Load(filepath.Join(dir, "new.toml")); written.GetInt("port", 0) != 1 {
+		t.Log("the file linked should be created")
+		t.Fail()
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 5 {
+		t.Log("no temporary file should be left, got", entries)
+		t.Fail()
+	}
+}
+
+func TestWriteWithErrors(t *testing.T) {
+	toml := NewToml()
+	toml.Set("port", 8080)
+	if err := Write(nil, filepath.Join(t.TempDir(), "app.toml")); err == nil {
+		t.Log("a nil document should be reported")
+		t.Fail()
+	}
+	if err := Write(toml, filepath.Join(t.TempDir(), "missing", "app.toml")); !os.IsNotExist(err) {
+		t.Log("a missing directory should be reported, got", err)
+		t.Fail()
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.toml")
+	Write(toml, path)
+	os.Mkdir(path+".bak", 0755)
+	os.WriteFile(filepath.Join(path+".bak", "keep"), nil, 0644)
+	if err := WriteWith(toml, path, &WriteOptions{Backup: true}); err == nil {
+		t.Log("a backup which cannot be made should be reported")
+		t.Fail()
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Log("the temporary file should be removed on errors, got", entries)
+		t.Fail()
+	}
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package fiptoml
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+//chown gives the file the owner of the one replaced, unless not permitted
+func chown(file *os.File, replaced fs.FileInfo) error {
+	st, ok := replaced.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	err := file.Chown(int(st.Uid), int(st.Gid))
+	if errors.Is(err, fs.ErrPermission) {
+		return nil
+	}
+	return err
+}
+
+//syncDir makes a rename in the directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.Sync(), d.Close())
+}